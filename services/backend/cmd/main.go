@@ -3,15 +3,20 @@ package main
 import (
 	"bufio"
 	"context"
+	"errors"
 	"fmt"
+	"io"
 	"log"
 	"net"
 	"net/http"
 	"os"
+	"os/signal"
 	"question-voting-app/internal/config"
 	"question-voting-app/internal/handlers"
 	"question-voting-app/internal/storage"
 	"question-voting-app/internal/ws"
+	"syscall"
+	"text/tabwriter"
 	"time"
 
 	"go.mongodb.org/mongo-driver/v2/mongo"
@@ -21,7 +26,15 @@ import (
 func main() {
 	cfg := config.Load()
 
-	ctx := context.Background()
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	if len(os.Args) > 1 && os.Args[1] == "migrate" {
+		if err := runMigrate(ctx, cfg, os.Args[2:], os.Stdout); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
 
 	var storer storage.Storer
 	switch cfg.DBDriver {
@@ -43,6 +56,24 @@ func main() {
 		fmt.Println("Connected to MongoDB!")
 		storer = storage.NewMongoStorage(client, "question-voting-app", "sessions")
 
+	case "postgres":
+		connCtx, cancel := context.WithTimeout(ctx, 10*time.Second)
+		defer cancel()
+
+		pgStorer, err := storage.NewPostgresStorage(connCtx, cfg.PostgresURL)
+		if err != nil {
+			log.Fatalf("Failed to connect to PostgreSQL: %v", err)
+		}
+		fmt.Println("Connected to PostgreSQL!")
+		storer = pgStorer
+
+	case "memory":
+		memStorer, err := storage.NewMemoryStorage(cfg.MemorySnapshotFile, cfg.MemorySnapshotInterval)
+		if err != nil {
+			log.Fatalf("Failed to restore in-memory snapshot: %v", err)
+		}
+		storer = memStorer
+
 	default: // "sqlite"
 		sqliteStorer, err := storage.NewSQLiteStorage(cfg.SQLiteFile)
 		if err != nil {
@@ -56,14 +87,114 @@ func main() {
 	}
 
 	isProduction := os.Getenv("ENV") == "production"
-	hub := ws.NewHub(isProduction)
+	var hub *ws.Hub
+	if cfg.RedisURL != "" {
+		broker, err := ws.NewRedisBroker(ctx, cfg.RedisURL)
+		if err != nil {
+			log.Fatalf("Failed to connect to Redis: %v", err)
+		}
+		if hub, err = ws.NewHubWithBroker(isProduction, broker); err != nil {
+			log.Fatalf("Failed to subscribe to Redis: %v", err)
+		}
+		fmt.Println("Connected to Redis!")
+	} else {
+		hub = ws.NewHub(isProduction)
+	}
+	hub.SetVoteWindow(cfg.VoteBatchWindow)
 	api := handlers.New(storer, cfg.SecureCookie, hub)
+	api.SessionTTL = cfg.SessionTTL
+	api.ArchiveRetention = cfg.ArchiveRetention
+	go api.RunExpiryWarnings(ctx, time.Minute, cfg.SessionExpiryWarning)
 
 	mux := SetupRouter(api, cfg.CORSOrigins)
 
 	// --- Server Start ---
+	server := &http.Server{Addr: ":" + cfg.Port, Handler: mux}
+	shutdown := make(chan struct{})
+	go func() {
+		defer close(shutdown)
+		<-ctx.Done()
+		fmt.Println("Shutting down...")
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		if err := server.Shutdown(shutdownCtx); err != nil {
+			log.Printf("Failed to shut down cleanly: %v", err)
+		}
+	}()
 	fmt.Printf("Starting server on http://localhost%s\n", cfg.Port)
-	log.Fatal(http.ListenAndServe(":"+cfg.Port, mux))
+	if err := server.ListenAndServe(); !errors.Is(err, http.ErrServerClosed) {
+		log.Fatal(err)
+	}
+	<-shutdown
+
+	// Stores that keep state in the process, such as the in-memory one,
+	// persist it on Close.
+	if closer, ok := storer.(io.Closer); ok {
+		if err := closer.Close(); err != nil {
+			log.Fatalf("Failed to close storage: %v", err)
+		}
+	}
+}
+
+// runMigrate implements the "migrate" subcommand:
+//
+//	main migrate [up]    apply all pending schema migrations
+//	main migrate status  list migrations and when they were applied
+func runMigrate(ctx context.Context, cfg *config.Config, args []string, out io.Writer) error {
+	var store interface {
+		Migrate(ctx context.Context) ([]storage.Migration, error)
+		MigrationStatus(ctx context.Context) ([]storage.MigrationStatus, error)
+	}
+	var err error
+	switch cfg.DBDriver {
+	case "sqlite":
+		store, err = storage.NewSQLiteStorage(cfg.SQLiteFile)
+	case "postgres":
+		store, err = storage.NewPostgresStorage(ctx, cfg.PostgresURL)
+	default:
+		return fmt.Errorf("migrations are not supported for DB_DRIVER=%q", cfg.DBDriver)
+	}
+	if err != nil {
+		return err
+	}
+
+	cmd := "up"
+	if len(args) > 0 {
+		cmd = args[0]
+	}
+	switch cmd {
+	case "up":
+		applied, err := store.Migrate(ctx)
+		for _, m := range applied {
+			fmt.Fprintf(out, "Applied %04d_%s\n", m.Version, m.Name)
+		}
+		if err != nil {
+			return err
+		}
+		if len(applied) == 0 {
+			fmt.Fprintln(out, "No pending migrations.")
+		}
+		return nil
+
+	case "status":
+		statuses, err := store.MigrationStatus(ctx)
+		if err != nil {
+			return err
+		}
+		tw := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(tw, "VERSION\tNAME\tAPPLIED")
+		for _, st := range statuses {
+			applied := "pending"
+			if st.Applied() {
+				applied = st.AppliedAt.Format(time.RFC3339)
+			}
+			fmt.Fprintf(tw, "%04d\t%s\t%s\n", st.Version, st.Name, applied)
+		}
+		return tw.Flush()
+
+	default:
+		return fmt.Errorf("unknown migrate command %q (expected \"up\" or \"status\")", cmd)
+	}
 }
 
 // responseWriter wraps http.ResponseWriter to capture the status code.
@@ -81,6 +212,12 @@ func (rw *responseWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
 	return rw.ResponseWriter.(http.Hijacker).Hijack()
 }
 
+// Unwrap lets http.ResponseController reach the wrapped writer, to flush
+// Server-Sent Events.
+func (rw *responseWriter) Unwrap() http.ResponseWriter {
+	return rw.ResponseWriter
+}
+
 // SetupRouter configures the API routes and applies CORS middleware.
 func SetupRouter(api *handlers.API, corsOrigins string) http.Handler {
 	// --- Request Logging Middleware ---
@@ -97,8 +234,9 @@ func SetupRouter(api *handlers.API, corsOrigins string) http.Handler {
 	corsHandler := func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 			w.Header().Set("Access-Control-Allow-Origin", corsOrigins)
-			w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
-			w.Header().Set("Access-Control-Allow-Headers", "Content-Type")
+			w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, PATCH, DELETE, OPTIONS")
+			w.Header().Set("Access-Control-Allow-Headers", "Content-Type, If-None-Match")
+			w.Header().Set("Access-Control-Expose-Headers", "ETag")
 			w.Header().Set("Access-Control-Allow-Credentials", "true")
 
 			if r.Method == "OPTIONS" {
@@ -118,12 +256,23 @@ func SetupRouter(api *handlers.API, corsOrigins string) http.Handler {
 
 	// Session Sub-resources
 	mux.HandleFunc("GET /api/session/{session_id}/check-admin", api.CheckAdminHandler)
+	mux.HandleFunc("GET /api/session/{session_id}/archive", api.ArchiveHandler)
+	mux.HandleFunc("GET /api/session/{session_id}/export", api.ExportSessionHandler)
+	mux.HandleFunc("POST /api/session/{session_id}/extend", api.ExtendSessionHandler)
+	mux.HandleFunc("POST /api/session/{session_id}/close", api.CloseSessionHandler)
+	mux.HandleFunc("POST /api/session/{session_id}/reopen", api.ReopenSessionHandler)
 	mux.HandleFunc("GET /api/session/{session_id}/ws", api.ServeWS)
+	mux.HandleFunc("GET /api/session/{session_id}/events", api.ServeEvents)
 
 	// Questions & Voting
 	mux.HandleFunc("POST /api/session/{session_id}/questions", api.SubmitQuestionHandler)
+	mux.HandleFunc("POST /api/session/{session_id}/questions/import", api.ImportQuestionsHandler)
+	mux.HandleFunc("PATCH /api/session/{session_id}/questions/{question_id}", api.UpdateQuestionHandler)
 	mux.HandleFunc("DELETE /api/session/{session_id}/questions/{question_id}", api.DeleteQuestionHandler)
+	mux.HandleFunc("POST /api/session/{session_id}/questions/{question_id}/approve", api.ApproveQuestionHandler)
+	mux.HandleFunc("POST /api/session/{session_id}/questions/{question_id}/reject", api.RejectQuestionHandler)
 	mux.HandleFunc("PUT /api/session/{session_id}/questions/{question_id}/vote", api.VoteQuestionHandler)
+	mux.HandleFunc("DELETE /api/session/{session_id}/questions/{question_id}/vote", api.UnvoteQuestionHandler)
 
 	// Moderation
 	mux.HandleFunc("POST /api/session/{session_id}/ban", api.BanIPHandler)
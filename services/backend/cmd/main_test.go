@@ -1,11 +1,18 @@
 package main
 
 import (
+	"bufio"
+	"bytes"
+	"context"
 	"io"
 	"net/http"
 	"net/http/httptest"
+	"path/filepath"
+	"question-voting-app/internal/config"
 	"question-voting-app/internal/handlers"
+	"question-voting-app/internal/models"
 	"question-voting-app/internal/testutil"
+	"question-voting-app/internal/ws"
 	"strings"
 	"testing"
 )
@@ -69,3 +76,64 @@ func TestSetupRouter_Routes(t *testing.T) {
 		})
 	}
 }
+
+func TestSetupRouter_StreamsEvents(t *testing.T) {
+	storer := testutil.NewMockStorer()
+	storer.PreloadSession(&models.SessionData{SessionID: "sse"})
+	api := handlers.New(storer, false, ws.NewHub(false))
+	server := httptest.NewServer(SetupRouter(api, "*"))
+	defer server.Close()
+
+	resp, err := http.Get(server.URL + "/api/session/sse/events")
+	if err != nil {
+		t.Fatalf("request failed: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("Expected 200 through the middleware, got %d", resp.StatusCode)
+	}
+
+	line, err := bufio.NewReader(resp.Body).ReadString('\n')
+	if err != nil {
+		t.Fatalf("read failed: %v", err)
+	}
+	if !strings.HasPrefix(line, "id: ") {
+		t.Errorf("Expected the SNAPSHOT's event ID first, got %q", line)
+	}
+}
+
+func TestRunMigrate(t *testing.T) {
+	ctx := context.Background()
+	cfg := &config.Config{DBDriver: "sqlite", SQLiteFile: filepath.Join(t.TempDir(), "migrate.db")}
+
+	var out bytes.Buffer
+	if err := runMigrate(ctx, cfg, []string{"status"}, &out); err != nil {
+		t.Fatalf("status failed: %v", err)
+	}
+	if !strings.Contains(out.String(), "0001") || !strings.Contains(out.String(), "pending") {
+		t.Errorf("Expected pending migrations in status output, got:\n%s", out.String())
+	}
+
+	out.Reset()
+	if err := runMigrate(ctx, cfg, nil, &out); err != nil {
+		t.Fatalf("up failed: %v", err)
+	}
+	if !strings.Contains(out.String(), "Applied 0001_") {
+		t.Errorf("Expected applied migrations in output, got:\n%s", out.String())
+	}
+
+	out.Reset()
+	if err := runMigrate(ctx, cfg, []string{"up"}, &out); err != nil {
+		t.Fatalf("second up failed: %v", err)
+	}
+	if !strings.Contains(out.String(), "No pending migrations") {
+		t.Errorf("Expected no pending migrations on second run, got:\n%s", out.String())
+	}
+
+	if err := runMigrate(ctx, cfg, []string{"down"}, &out); err == nil {
+		t.Error("Expected an error for an unknown migrate command")
+	}
+	if err := runMigrate(ctx, &config.Config{DBDriver: "mongodb"}, nil, &out); err == nil {
+		t.Error("Expected an error for an unsupported driver")
+	}
+}
@@ -0,0 +1,164 @@
+// Package export renders a session's questions as downloadable reports.
+package export
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"question-voting-app/internal/models"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Report is the format-independent content of an export: the session's
+// questions ranked by votes. It leaves out voters, submitter IPs and the admin
+// token.
+type Report struct {
+	SessionID    string     `json:"sessionId"`
+	SessionTitle string     `json:"sessionTitle"`
+	CreatedAt    time.Time  `json:"createdAt"`
+	ExportedAt   time.Time  `json:"exportedAt"`
+	Questions    []Question `json:"questions"`
+}
+
+// Question is a ranked question in a Report.
+type Question struct {
+	Rank      int       `json:"rank"`
+	Text      string    `json:"text"`
+	Votes     int       `json:"votes"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+// NewReport ranks the questions of data by votes. Questions with equal votes
+// share a rank and keep their submission order.
+func NewReport(data *models.SessionData, exportedAt time.Time) *Report {
+	questions := make([]Question, len(data.Questions))
+	for i, q := range data.Questions {
+		questions[i] = Question{Text: q.Text, Votes: q.Votes, CreatedAt: q.CreatedAt}
+	}
+	sort.SliceStable(questions, func(i, j int) bool {
+		return questions[i].Votes > questions[j].Votes
+	})
+	for i := range questions {
+		if i > 0 && questions[i].Votes == questions[i-1].Votes {
+			questions[i].Rank = questions[i-1].Rank
+		} else {
+			questions[i].Rank = i + 1
+		}
+	}
+	return &Report{
+		SessionID:    data.SessionID,
+		SessionTitle: data.SessionTitle,
+		CreatedAt:    data.CreatedAt,
+		ExportedAt:   exportedAt,
+		Questions:    questions,
+	}
+}
+
+// Exporter writes a Report in one file format.
+type Exporter interface {
+	// ContentType is the MIME type of the output.
+	ContentType() string
+	// Extension is the file name extension of the output, without the dot.
+	Extension() string
+	Export(w io.Writer, r *Report) error
+}
+
+// exporters maps the format names accepted by Lookup to their Exporter.
+var exporters = map[string]Exporter{
+	"json": jsonExporter{},
+	"csv":  csvExporter{},
+	"md":   markdownExporter{},
+}
+
+// Lookup returns the Exporter for format.
+func Lookup(format string) (Exporter, bool) {
+	e, ok := exporters[format]
+	return e, ok
+}
+
+// Formats returns the supported format names, sorted.
+func Formats() []string {
+	formats := make([]string, 0, len(exporters))
+	for format := range exporters {
+		formats = append(formats, format)
+	}
+	sort.Strings(formats)
+	return formats
+}
+
+// formatTime renders t for the CSV and Markdown exports; unknown times, such
+// as those of questions submitted before timestamps were recorded, are blank.
+func formatTime(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.UTC().Format(time.RFC3339)
+}
+
+type jsonExporter struct{}
+
+func (jsonExporter) ContentType() string { return "application/json" }
+func (jsonExporter) Extension() string   { return "json" }
+
+func (jsonExporter) Export(w io.Writer, r *Report) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(r)
+}
+
+type csvExporter struct{}
+
+func (csvExporter) ContentType() string { return "text/csv; charset=utf-8" }
+func (csvExporter) Extension() string   { return "csv" }
+
+func (csvExporter) Export(w io.Writer, r *Report) error {
+	cw := csv.NewWriter(w)
+	cw.Write([]string{"rank", "votes", "question", "created_at"})
+	for _, q := range r.Questions {
+		cw.Write([]string{strconv.Itoa(q.Rank), strconv.Itoa(q.Votes), csvSafe(q.Text), formatTime(q.CreatedAt)})
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// csvSafe prefixes text that a spreadsheet would evaluate as a formula.
+func csvSafe(text string) string {
+	if text != "" && strings.ContainsRune("=+-@\t\r", rune(text[0])) {
+		return "'" + text
+	}
+	return text
+}
+
+type markdownExporter struct{}
+
+func (markdownExporter) ContentType() string { return "text/markdown; charset=utf-8" }
+func (markdownExporter) Extension() string   { return "md" }
+
+func (markdownExporter) Export(w io.Writer, r *Report) error {
+	var b strings.Builder
+	fmt.Fprintf(&b, "# %s\n\n", markdownCell(r.SessionTitle))
+	fmt.Fprintf(&b, "Session `%s`, created %s, exported %s.\n\n",
+		r.SessionID, formatTime(r.CreatedAt), formatTime(r.ExportedAt))
+	if len(r.Questions) == 0 {
+		b.WriteString("No questions were asked.\n")
+	} else {
+		b.WriteString("| Rank | Votes | Question | Asked at |\n")
+		b.WriteString("| ---: | ----: | -------- | -------- |\n")
+		for _, q := range r.Questions {
+			fmt.Fprintf(&b, "| %d | %d | %s | %s |\n", q.Rank, q.Votes, markdownCell(q.Text), formatTime(q.CreatedAt))
+		}
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+var markdownCellReplacer = strings.NewReplacer("|", `\|`, "\r\n", " ", "\n", " ", "\r", " ")
+
+// markdownCell escapes text so it stays within one table cell.
+func markdownCell(text string) string {
+	return markdownCellReplacer.Replace(text)
+}
@@ -0,0 +1,116 @@
+package export
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"question-voting-app/internal/models"
+	"strings"
+	"testing"
+	"time"
+)
+
+func testSession() *models.SessionData {
+	asked := time.Date(2025, 3, 1, 10, 0, 0, 0, time.UTC)
+	return &models.SessionData{
+		SessionID:    "all-hands",
+		SessionTitle: "All Hands",
+		AdminToken:   "secret",
+		CreatedAt:    asked.Add(-time.Hour),
+		Questions: []models.Question{
+			{ID: "q1", Text: "Roadmap?", Votes: 2, Voters: []string{"u1", "u2"}, CreatedAt: asked},
+			{ID: "q2", Text: "=HYPERLINK(\"x\") | pipes", Votes: 5, Voters: []string{"u3"}, SubmitterIP: "1.2.3.4"},
+			{ID: "q3", Text: "Hiring\nplans?", Votes: 2, CreatedAt: asked.Add(time.Minute)},
+		},
+	}
+}
+
+func TestNewReport(t *testing.T) {
+	r := NewReport(testSession(), time.Now())
+
+	want := []struct {
+		rank  int
+		text  string
+		votes int
+	}{
+		{1, "=HYPERLINK(\"x\") | pipes", 5},
+		{2, "Roadmap?", 2},
+		{2, "Hiring\nplans?", 2},
+	}
+	if len(r.Questions) != len(want) {
+		t.Fatalf("Expected %d questions, got %d", len(want), len(r.Questions))
+	}
+	for i, w := range want {
+		q := r.Questions[i]
+		if q.Rank != w.rank || q.Text != w.text || q.Votes != w.votes {
+			t.Errorf("Question %d: expected rank %d %q (%d votes), got %+v", i, w.rank, w.text, w.votes, q)
+		}
+	}
+}
+
+func TestLookup(t *testing.T) {
+	for _, format := range []string{"json", "csv", "md"} {
+		if _, ok := Lookup(format); !ok {
+			t.Errorf("Expected format %q to be supported", format)
+		}
+	}
+	if _, ok := Lookup("xlsx"); ok {
+		t.Error("Expected format xlsx to be unsupported")
+	}
+}
+
+func TestExporters(t *testing.T) {
+	r := NewReport(testSession(), time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC))
+
+	export := func(t *testing.T, format string) string {
+		t.Helper()
+		e, _ := Lookup(format)
+		var buf bytes.Buffer
+		if err := e.Export(&buf, r); err != nil {
+			t.Fatalf("Export failed: %v", err)
+		}
+		if out := buf.String(); strings.Contains(out, "secret") || strings.Contains(out, "1.2.3.4") || strings.Contains(out, "u1") {
+			t.Errorf("Export leaks private session data:\n%s", out)
+		}
+		return buf.String()
+	}
+
+	t.Run("json", func(t *testing.T) {
+		var got Report
+		if err := json.Unmarshal([]byte(export(t, "json")), &got); err != nil {
+			t.Fatalf("Invalid JSON: %v", err)
+		}
+		if got.SessionTitle != "All Hands" || len(got.Questions) != 3 || got.Questions[0].Votes != 5 {
+			t.Errorf("Unexpected report %+v", got)
+		}
+	})
+
+	t.Run("csv", func(t *testing.T) {
+		records, err := csv.NewReader(strings.NewReader(export(t, "csv"))).ReadAll()
+		if err != nil {
+			t.Fatalf("Invalid CSV: %v", err)
+		}
+		if len(records) != 4 || strings.Join(records[0], ",") != "rank,votes,question,created_at" {
+			t.Fatalf("Unexpected CSV records %q", records)
+		}
+		if records[1][2] != "'=HYPERLINK(\"x\") | pipes" {
+			t.Errorf("Expected formula to be neutralised, got %q", records[1][2])
+		}
+		if records[1][3] != "" || records[2][3] != "2025-03-01T10:00:00Z" {
+			t.Errorf("Unexpected timestamps %q, %q", records[1][3], records[2][3])
+		}
+	})
+
+	t.Run("md", func(t *testing.T) {
+		out := export(t, "md")
+		if !strings.HasPrefix(out, "# All Hands\n") {
+			t.Errorf("Expected title heading, got:\n%s", out)
+		}
+		if !strings.Contains(out, `| 1 | 5 | =HYPERLINK("x") \| pipes |  |`) {
+			t.Errorf("Expected escaped pipe in table, got:\n%s", out)
+		}
+		if !strings.Contains(out, "| 2 | 2 | Hiring plans? | 2025-03-01T10:01:00Z |") {
+			t.Errorf("Expected newline to be flattened, got:\n%s", out)
+		}
+	})
+}
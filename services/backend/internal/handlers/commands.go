@@ -0,0 +1,123 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"github.com/google/uuid"
+
+	"question-voting-app/internal/models"
+	"question-voting-app/internal/ws"
+)
+
+// wsCommand maps a WebSocket command onto the HTTP handler that implements it.
+type wsCommand struct {
+	method string
+	// path is the route below /api/session/{session_id}.
+	path string
+	// question tells whether the command needs a questionId.
+	question bool
+	handle   func(a *API, w http.ResponseWriter, r *http.Request)
+}
+
+// wsCommands are the commands a WebSocket client can send instead of HTTP
+// requests. Their payload is the request body; admin commands take the admin
+// token in the command's token field, or use the connection's admin role.
+var wsCommands = map[string]wsCommand{
+	"SUBMIT_QUESTION": {http.MethodPost, "/questions", false, (*API).SubmitQuestionHandler},
+	"VOTE":            {http.MethodPut, "/questions/{question_id}/vote", true, (*API).VoteQuestionHandler},
+	"UNVOTE":          {http.MethodDelete, "/questions/{question_id}/vote", true, (*API).UnvoteQuestionHandler},
+
+	"UPDATE_QUESTION":  {http.MethodPatch, "/questions/{question_id}", true, (*API).UpdateQuestionHandler},
+	"DELETE_QUESTION":  {http.MethodDelete, "/questions/{question_id}", true, (*API).DeleteQuestionHandler},
+	"APPROVE_QUESTION": {http.MethodPost, "/questions/{question_id}/approve", true, (*API).ApproveQuestionHandler},
+	"REJECT_QUESTION":  {http.MethodPost, "/questions/{question_id}/reject", true, (*API).RejectQuestionHandler},
+	"BAN_IP":           {http.MethodPost, "/ban", false, (*API).BanIPHandler},
+	"CLOSE_SESSION":    {http.MethodPost, "/close", false, (*API).CloseSessionHandler},
+	"REOPEN_SESSION":   {http.MethodPost, "/reopen", false, (*API).ReopenSessionHandler},
+	"EXTEND_SESSION":   {http.MethodPost, "/extend", false, (*API).ExtendSessionHandler},
+	"END_SESSION":      {http.MethodDelete, "", false, (*API).EndSessionHandler},
+}
+
+// commandRunner returns the function that runs the commands of the WebSocket
+// client connected by upgrade request r. Each command is handled as the
+// equivalent HTTP request from the same client: same IP, same voter cookie.
+func (a *API) commandRunner(r *http.Request, sessionData *models.SessionData) func(ws.Role, ws.Command) ws.Ack {
+	sessionID := sessionData.SessionID
+	adminToken := sessionData.AdminToken
+	header := r.Header.Clone()
+	remoteAddr := r.RemoteAddr
+
+	// Without a voter cookie the connection gets an ID of its own, so that
+	// its votes are counted once.
+	var userID string
+	if cookie, err := r.Cookie(userSessionIDCookie); err == nil {
+		userID = cookie.Value
+	} else {
+		userID = uuid.New().String()
+	}
+
+	return func(role ws.Role, cmd ws.Command) ws.Ack {
+		command, ok := wsCommands[cmd.Type]
+		if !ok {
+			return ws.Ack{Status: http.StatusBadRequest, Error: "Unknown command"}
+		}
+		if command.question && cmd.QuestionID == "" {
+			return ws.Ack{Status: http.StatusBadRequest, Error: "Missing questionId"}
+		}
+
+		path := "/api/session/" + sessionID + strings.Replace(command.path, "{question_id}", cmd.QuestionID, 1)
+		// The upgrade request's context ends with ServeWS, so commands
+		// get their own.
+		req, err := http.NewRequestWithContext(context.Background(), command.method, path, bytes.NewReader(cmd.Payload))
+		if err != nil {
+			return ws.Ack{Status: http.StatusBadRequest, Error: "Invalid command"}
+		}
+		req.Header = header.Clone()
+		req.Header.Del("Cookie")
+		req.Header.Del(authHeader)
+		req.AddCookie(&http.Cookie{Name: userSessionIDCookie, Value: userID})
+		switch {
+		case cmd.Token != "":
+			req.Header.Set(authHeader, "Bearer "+cmd.Token)
+		case role == ws.RoleAdmin:
+			req.Header.Set(authHeader, "Bearer "+adminToken)
+		}
+		req.Header.Set("Content-Type", "application/json")
+		req.RemoteAddr = remoteAddr
+		req.SetPathValue("session_id", sessionID)
+		req.SetPathValue("question_id", cmd.QuestionID)
+
+		rec := &commandRecorder{header: make(http.Header), status: http.StatusOK}
+		command.handle(a, rec, req)
+		return rec.ack()
+	}
+}
+
+// commandRecorder is the http.ResponseWriter of a command's request.
+type commandRecorder struct {
+	header http.Header
+	status int
+	body   bytes.Buffer
+}
+
+func (rec *commandRecorder) Header() http.Header         { return rec.header }
+func (rec *commandRecorder) Write(b []byte) (int, error) { return rec.body.Write(b) }
+func (rec *commandRecorder) WriteHeader(status int)      { rec.status = status }
+
+// ack turns the recorded response into an Ack: a JSON body becomes its
+// payload, and the text of an error response its error.
+func (rec *commandRecorder) ack() ws.Ack {
+	ack := ws.Ack{Status: rec.status}
+	body := bytes.TrimSpace(rec.body.Bytes())
+	switch {
+	case rec.status >= http.StatusBadRequest:
+		ack.Error = string(body)
+	case len(body) > 0 && json.Valid(body):
+		ack.Payload = body
+	}
+	return ack
+}
@@ -0,0 +1,119 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+func TestWebSocketCommands(t *testing.T) {
+	api, storer := setupTestAPI()
+	sessionID := "command-session"
+	questionID := "00000000-0000-0000-0000-000000000011"
+	storer.PreloadSession(createMockSession(sessionID, "admin-1", true))
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /api/session/{session_id}/ws", api.ServeWS)
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(server.URL, "http")+"/api/session/"+sessionID+"/ws",
+		http.Header{"Cookie": {userSessionIDCookie + "=ws-voter"}})
+	if err != nil {
+		t.Fatalf("dial failed: %v", err)
+	}
+	defer conn.Close()
+
+	type message struct {
+		Type    string                 `json:"type"`
+		ID      string                 `json:"id"`
+		OK      bool                   `json:"ok"`
+		Status  int                    `json:"status"`
+		Error   string                 `json:"error"`
+		Payload map[string]interface{} `json:"payload"`
+	}
+	// send sends a command and returns its ACK, collecting the events that
+	// arrive before it.
+	send := func(t *testing.T, cmd map[string]interface{}) (message, []string) {
+		t.Helper()
+		if err := conn.WriteJSON(cmd); err != nil {
+			t.Fatalf("write failed: %v", err)
+		}
+		var events []string
+		for {
+			conn.SetReadDeadline(time.Now().Add(time.Second))
+			var msg message
+			if err := conn.ReadJSON(&msg); err != nil {
+				t.Fatalf("read failed: %v", err)
+			}
+			if msg.Type == "ACK" {
+				return msg, events
+			}
+			events = append(events, msg.Type)
+		}
+	}
+
+	t.Run("Vote", func(t *testing.T) {
+		ack, events := send(t, map[string]interface{}{"type": "VOTE", "id": "r1", "questionId": questionID})
+		if !ack.OK || ack.ID != "r1" || ack.Status != http.StatusOK || ack.Payload["hasVoted"] != true {
+			t.Errorf("Expected a successful ACK for r1, got %+v", ack)
+		}
+		if !slices.Contains(events, "VOTE_UPDATED") {
+			t.Errorf("Expected VOTE_UPDATED before the ACK, got %v", events)
+		}
+		session, _ := storer.LoadSessionData(t.Context(), sessionID)
+		if !slices.Contains(session.Questions[0].Voters, "ws-voter") {
+			t.Errorf("Expected the vote to be cast with the connection's cookie, got voters %v", session.Questions[0].Voters)
+		}
+
+		ack, _ = send(t, map[string]interface{}{"type": "VOTE", "id": "r2", "questionId": questionID})
+		if ack.OK || ack.ID != "r2" || ack.Status != http.StatusForbidden {
+			t.Errorf("Expected a 403 ACK for a second vote, got %+v", ack)
+		}
+	})
+
+	t.Run("Unvote", func(t *testing.T) {
+		ack, _ := send(t, map[string]interface{}{"type": "UNVOTE", "id": "r3", "questionId": questionID})
+		if !ack.OK {
+			t.Errorf("Expected a successful ACK, got %+v", ack)
+		}
+	})
+
+	t.Run("SubmitQuestion", func(t *testing.T) {
+		ack, events := send(t, map[string]interface{}{"type": "SUBMIT_QUESTION", "id": "r4", "payload": map[string]string{"text": "Over the socket?"}})
+		if !ack.OK || ack.Status != http.StatusCreated || ack.Payload["text"] != "Over the socket?" {
+			t.Errorf("Expected the created question in the ACK, got %+v", ack)
+		}
+		if !slices.Contains(events, "QUESTION_ADDED") {
+			t.Errorf("Expected QUESTION_ADDED before the ACK, got %v", events)
+		}
+	})
+
+	t.Run("AdminCommandNeedsToken", func(t *testing.T) {
+		cmd := map[string]interface{}{"type": "UPDATE_QUESTION", "id": "r5", "questionId": questionID, "payload": map[string]string{"status": "pinned"}}
+		if ack, _ := send(t, cmd); ack.OK || ack.Status != http.StatusForbidden {
+			t.Errorf("Expected a 403 ACK without the token, got %+v", ack)
+		}
+		cmd["token"] = "admin-1"
+		if ack, _ := send(t, cmd); !ack.OK || ack.Payload["status"] != "pinned" {
+			t.Errorf("Expected the updated question in the ACK, got %+v", ack)
+		}
+	})
+
+	t.Run("InvalidCommands", func(t *testing.T) {
+		for _, cmd := range []map[string]interface{}{
+			{"type": "FLY", "id": "r6"},
+			{"type": "VOTE", "id": "r7"},
+			{"id": "r8"},
+		} {
+			if ack, _ := send(t, cmd); ack.OK || ack.Status != http.StatusBadRequest || ack.ID != cmd["id"] {
+				t.Errorf("Expected a 400 ACK for %v, got %+v", cmd, ack)
+			}
+		}
+	})
+}
@@ -0,0 +1,60 @@
+package handlers
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"question-voting-app/internal/ws"
+)
+
+// RunExpiryWarnings checks the sessions with connected WebSocket clients every
+// interval and sends SESSION_EXPIRING once a session is due to expire within
+// window. Each expiry time is announced once; extending the session re-arms
+// the warning. Every instance runs it for its own clients only, so that
+// clients are warned once however many instances there are. It returns when
+// ctx is done.
+func (a *API) RunExpiryWarnings(ctx context.Context, interval, window time.Duration) {
+	if a.Hub == nil || window <= 0 {
+		return
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	warned := make(map[string]time.Time) // sessionID -> expiry already announced
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			a.warnExpiringSessions(ctx, window, warned)
+		}
+	}
+}
+
+// warnExpiringSessions performs a single RunExpiryWarnings pass.
+func (a *API) warnExpiringSessions(ctx context.Context, window time.Duration, warned map[string]time.Time) {
+	active := make(map[string]bool)
+	for _, sessionID := range a.Hub.SessionIDs() {
+		active[sessionID] = true
+
+		sessionData, err := a.Storer.LoadSessionData(ctx, sessionID)
+		if err != nil {
+			if !isNotFoundError(err) {
+				log.Printf("Expiry check for session %q failed: %v", sessionID, err)
+			}
+			continue
+		}
+		remaining := time.Until(sessionData.ExpiresAt)
+		if remaining <= 0 || remaining > window || warned[sessionID].Equal(sessionData.ExpiresAt) {
+			continue
+		}
+		warned[sessionID] = sessionData.ExpiresAt
+		a.Hub.BroadcastLocal(sessionID, ws.Event{Type: "SESSION_EXPIRING", Payload: map[string]time.Time{"expiresAt": sessionData.ExpiresAt}})
+	}
+	for sessionID := range warned {
+		if !active[sessionID] {
+			delete(warned, sessionID)
+		}
+	}
+}
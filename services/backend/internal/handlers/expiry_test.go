@@ -0,0 +1,127 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+
+	"question-voting-app/internal/testutil"
+	"question-voting-app/internal/ws"
+)
+
+// nextEvent returns the type of the next event queued for client, or "" if none is.
+func nextEvent(t *testing.T, client *ws.Client) string {
+	t.Helper()
+	select {
+	case msg := <-client.Send:
+		var event struct {
+			Type string `json:"type"`
+		}
+		if err := json.Unmarshal(msg, &event); err != nil {
+			t.Fatalf("invalid event %q: %v", msg, err)
+		}
+		return event.Type
+	default:
+		return ""
+	}
+}
+
+func TestWarnExpiringSessions(t *testing.T) {
+	api, storer := setupTestAPI()
+	ctx := context.Background()
+	window := 10 * time.Minute
+
+	expiring := createMockSession("expiring-session", "token", true)
+	expiring.ExpiresAt = time.Now().Add(5 * time.Minute)
+	storer.PreloadSession(expiring)
+	later := createMockSession("later-session", "token", true)
+	later.ExpiresAt = time.Now().Add(time.Hour)
+	storer.PreloadSession(later)
+
+	expiringClient := &ws.Client{SessionID: expiring.SessionID, Send: make(chan []byte, 4)}
+	laterClient := &ws.Client{SessionID: later.SessionID, Send: make(chan []byte, 4)}
+	api.Hub.Register(expiringClient)
+	api.Hub.Register(laterClient)
+
+	warned := make(map[string]time.Time)
+	api.warnExpiringSessions(ctx, window, warned)
+	if got := nextEvent(t, expiringClient); got != "SESSION_EXPIRING" {
+		t.Errorf("Expected SESSION_EXPIRING for the expiring session, got %q", got)
+	}
+	if got := nextEvent(t, laterClient); got != "" {
+		t.Errorf("Expected no event for a session outside the window, got %q", got)
+	}
+
+	t.Run("warns only once per expiry", func(t *testing.T) {
+		api.warnExpiringSessions(ctx, window, warned)
+		if got := nextEvent(t, expiringClient); got != "" {
+			t.Errorf("Expected no repeated warning, got %q", got)
+		}
+	})
+
+	t.Run("warns again after an extension", func(t *testing.T) {
+		extended := createMockSession(expiring.SessionID, "token", true)
+		extended.ExpiresAt = time.Now().Add(8 * time.Minute)
+		storer.PreloadSession(extended)
+		api.warnExpiringSessions(ctx, window, warned)
+		if got := nextEvent(t, expiringClient); got != "SESSION_EXPIRING" {
+			t.Errorf("Expected a new SESSION_EXPIRING after extension, got %q", got)
+		}
+	})
+
+	t.Run("forgets sessions without clients", func(t *testing.T) {
+		api.Hub.Unregister(expiringClient)
+		api.warnExpiringSessions(ctx, window, warned)
+		if _, ok := warned[expiring.SessionID]; ok {
+			t.Error("Expected warning state to be dropped once no clients are connected")
+		}
+	})
+}
+
+func TestWarnExpiringSessions_AcrossReplicas(t *testing.T) {
+	server := miniredis.RunT(t)
+	storer := testutil.NewMockStorer()
+	newReplica := func() *API {
+		broker, err := ws.NewRedisBroker(context.Background(), "redis://"+server.Addr())
+		if err != nil {
+			t.Fatalf("NewRedisBroker failed: %v", err)
+		}
+		t.Cleanup(func() { broker.Close() })
+		hub, err := ws.NewHubWithBroker(false, broker)
+		if err != nil {
+			t.Fatalf("NewHubWithBroker failed: %v", err)
+		}
+		return New(storer, false, hub)
+	}
+	replicas := []*API{newReplica(), newReplica()}
+
+	session := createMockSession("replicated-expiring-session", "token", true)
+	session.ExpiresAt = time.Now().Add(5 * time.Minute)
+	storer.PreloadSession(session)
+	clients := make([]*ws.Client, len(replicas))
+	for i, replica := range replicas {
+		clients[i] = &ws.Client{SessionID: session.SessionID, Send: make(chan []byte, 16)}
+		replica.Hub.Register(clients[i])
+		defer replica.Hub.Unregister(clients[i])
+	}
+
+	for _, replica := range replicas {
+		replica.warnExpiringSessions(context.Background(), 10*time.Minute, make(map[string]time.Time))
+	}
+	// Give any warning published through Redis time to arrive.
+	time.Sleep(100 * time.Millisecond)
+	for i, client := range clients {
+		warnings := 0
+		for event := nextEvent(t, client); event != ""; event = nextEvent(t, client) {
+			if event == "SESSION_EXPIRING" {
+				warnings++
+			}
+		}
+		if warnings != 1 {
+			t.Errorf("Expected client on replica %d to be warned once, got %d warnings", i, warnings)
+		}
+	}
+}
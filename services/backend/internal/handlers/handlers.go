@@ -1,18 +1,27 @@
 package handlers
 
 import (
+	"bytes"
 	"context"
 	"crypto/rand"
+	"encoding/csv"
 	"encoding/json"
 	"errors"
+	"fmt"
+	"io"
+	"log"
 	"math/big"
+	"mime"
 	"net"
 	"net/http"
+	"question-voting-app/internal/export"
 	"question-voting-app/internal/models"
 	"question-voting-app/internal/storage"
 	"question-voting-app/internal/ws"
 	"regexp"
+	"slices"
 	"sort"
+	"strconv"
 	"strings"
 	"time"
 
@@ -45,7 +54,11 @@ const (
 	userSessionIDCookie    = "userSessionId"
 	authHeader             = "Authorization"
 	maxRequestBodyBytes    = 4096
+	maxImportBodyBytes     = 256 << 10
 	maxQuestionsPerSession = 200
+	maxQuestionLength      = 500
+	maxVoteBudget          = 100
+	maxSessionExtension    = 30 * 24 * time.Hour
 )
 
 func slugify(s string) string {
@@ -84,17 +97,57 @@ func generateRandomString(n int) (string, error) {
 	return string(ret), nil
 }
 
-func newSessionData(sessionID, sessionTitle string) *models.SessionData {
+func newSessionData(sessionID, sessionTitle string, ttl time.Duration) *models.SessionData {
+	now := time.Now()
 	return &models.SessionData{
 		SessionID:    sessionID,
 		SessionTitle: sessionTitle,
 		AdminToken:   uuid.New().String(),
 		IsActive:     true,
-		CreatedAt:    time.Now(),
+		CreatedAt:    now,
+		ExpiresAt:    now.Add(ttl),
+		VotingMode:   models.VotingModeUpvote,
 		Questions:    []models.Question{},
 	}
 }
 
+// checkVotingMode validates the voting mode and budget requested for a new
+// session.
+func checkVotingMode(mode models.VotingMode, budget int) error {
+	switch mode {
+	case "", models.VotingModeUpvote, models.VotingModeUpDown:
+		if budget != 0 {
+			return errors.New("voteBudget is only allowed with the budget voting mode")
+		}
+	case models.VotingModeBudget:
+		if budget < 1 || budget > maxVoteBudget {
+			return fmt.Errorf("voteBudget must be between 1 and %d", maxVoteBudget)
+		}
+	default:
+		return fmt.Errorf("unknown voting mode %q", mode)
+	}
+	return nil
+}
+
+// votingMode returns the session's voting mode; sessions created before
+// voting modes existed are upvote-only.
+func votingMode(data *models.SessionData) models.VotingMode {
+	if data.VotingMode == "" {
+		return models.VotingModeUpvote
+	}
+	return data.VotingMode
+}
+
+// remainingVotes returns how many votes userID has left in the session, or nil
+// if the session has no vote budget.
+func remainingVotes(data *models.SessionData, userID string) *int {
+	if data.VoteBudget <= 0 {
+		return nil
+	}
+	remaining := max(data.VoteBudget-data.VotesCast(userID), 0)
+	return &remaining
+}
+
 func isNotFoundError(err error) bool {
 	return err != nil && errors.Is(err, storage.ErrNotFound)
 }
@@ -103,11 +156,32 @@ func isDuplicateKeyError(err error) bool {
 	return err != nil && errors.Is(err, storage.ErrDuplicateKey)
 }
 
+// sessionETag formats a session version as a strong HTTP entity tag.
+func sessionETag(version int64) string {
+	return `"` + strconv.FormatInt(version, 10) + `"`
+}
+
+// etagMatches reports whether an If-None-Match header value matches etag.
+func etagMatches(header, etag string) bool {
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimPrefix(strings.TrimSpace(candidate), "W/")
+		if candidate == etag || candidate == "*" {
+			return true
+		}
+	}
+	return false
+}
+
 // API holds the dependencies for the API handlers.
 type API struct {
 	Storer       storage.Storer
 	SecureCookie bool
 	Hub          *ws.Hub
+	SessionTTL   time.Duration // lifetime of new sessions and default extension
+
+	// ArchiveRetention is how long ended sessions stay readable through
+	// ArchiveHandler. Zero deletes ended sessions outright.
+	ArchiveRetention time.Duration
 }
 
 // New creates a new API instance.
@@ -116,7 +190,54 @@ func New(storer storage.Storer, secureCookie bool, hub *ws.Hub) *API {
 		Storer:       storer,
 		SecureCookie: secureCookie,
 		Hub:          hub,
+		SessionTTL:   storage.DefaultSessionTTL,
+
+		ArchiveRetention: 30 * 24 * time.Hour,
+	}
+}
+
+// broadcast sends an event to all WebSocket clients of a session. A nil
+// payload is omitted.
+func (a *API) broadcast(sessionID, eventType string, payload interface{}) {
+	if a.Hub == nil {
+		return
+	}
+	a.Hub.Broadcast(sessionID, ws.Event{Type: eventType, Payload: payload})
+}
+
+// broadcastVotes sends the new vote count of q to the session's WebSocket
+// clients, as VOTE_UPDATED or within a VOTES_BATCH.
+func (a *API) broadcastVotes(sessionID string, q *models.Question) {
+	if a.Hub == nil {
+		return
+	}
+	a.Hub.BroadcastVotes(sessionID, q.ID, q.Votes)
+}
+
+// participants returns the number of clients connected to the session, on
+// all instances.
+func (a *API) participants(sessionID string) int {
+	if a.Hub == nil {
+		return 0
+	}
+	return a.Hub.ClientCount(sessionID)
+}
+
+// broadcastToAdmins is like broadcast, but only reaches admin connections.
+func (a *API) broadcastToAdmins(sessionID, eventType string, payload interface{}) {
+	if a.Hub == nil {
+		return
+	}
+	a.Hub.BroadcastToRole(sessionID, ws.RoleAdmin, ws.Event{Type: eventType, Payload: payload})
+}
+
+// broadcastToParticipants is like broadcast, but only reaches participant
+// connections.
+func (a *API) broadcastToParticipants(sessionID, eventType string, payload interface{}) {
+	if a.Hub == nil {
+		return
 	}
+	a.Hub.BroadcastToRole(sessionID, ws.RoleParticipant, ws.Event{Type: eventType, Payload: payload})
 }
 
 // getUserSessionID extracts the userSessionId from the cookie or generates a new one.
@@ -140,12 +261,12 @@ func (a *API) getUserSessionID(w http.ResponseWriter, r *http.Request) string {
 	return newID
 }
 
-func (a *API) createSessionWithRetry(ctx context.Context, sessionID, sessionTitle string) (*models.SessionData, error) {
-	newSession := newSessionData(sessionID, sessionTitle)
+func (a *API) createSessionWithRetry(ctx context.Context, newSession *models.SessionData) (*models.SessionData, error) {
+	sessionID := newSession.SessionID
 
 	// Retry logic for session ID collision
 	for i := 0; i < 5; i++ {
-		err := a.Storer.CreateSessionData(ctx, newSession)
+		err := a.createSession(ctx, newSession)
 		if err == nil {
 			return newSession, nil
 		}
@@ -175,6 +296,19 @@ func (a *API) createSessionWithRetry(ctx context.Context, sessionID, sessionTitl
 	return nil, errors.New("failed to create session after multiple retries")
 }
 
+// createSession stores a new session, unless an archived session still holds
+// its ID: the new session would shadow the archive in exports.
+func (a *API) createSession(ctx context.Context, newSession *models.SessionData) error {
+	_, err := a.Storer.LoadArchivedSession(ctx, newSession.SessionID)
+	if err == nil {
+		return fmt.Errorf("%w: session %q is archived", storage.ErrDuplicateKey, newSession.SessionID)
+	}
+	if !isNotFoundError(err) {
+		return err
+	}
+	return a.Storer.CreateSessionData(ctx, newSession)
+}
+
 // CreateSessionHandler creates a new voting session.
 // POST /api/session
 func (a *API) CreateSessionHandler(w http.ResponseWriter, r *http.Request) {
@@ -192,6 +326,11 @@ func (a *API) CreateSessionHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if err := checkVotingMode(req.VotingMode, req.VoteBudget); err != nil {
+		http.Error(w, "Invalid voting mode: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
 	sessionID := req.SessionID
 	sessionTitle := req.SessionID
 	if sessionID != "" {
@@ -207,7 +346,14 @@ func (a *API) CreateSessionHandler(w http.ResponseWriter, r *http.Request) {
 		sessionID = randomString
 	}
 
-	newSession, err := a.createSessionWithRetry(r.Context(), sessionID, sessionTitle)
+	newSession := newSessionData(sessionID, sessionTitle, a.SessionTTL)
+	if req.VotingMode != "" {
+		newSession.VotingMode = req.VotingMode
+	}
+	newSession.VoteBudget = req.VoteBudget
+	newSession.Moderated = req.Moderated
+
+	newSession, err := a.createSessionWithRetry(r.Context(), newSession)
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
@@ -215,13 +361,123 @@ func (a *API) CreateSessionHandler(w http.ResponseWriter, r *http.Request) {
 
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusCreated)
-	json.NewEncoder(w).Encode(map[string]string{
+	json.NewEncoder(w).Encode(map[string]interface{}{
 		"sessionId":    newSession.SessionID,
 		"sessionTitle": newSession.SessionTitle,
 		"adminToken":   newSession.AdminToken,
+		"votingMode":   newSession.VotingMode,
+		"voteBudget":   newSession.VoteBudget,
+		"moderated":    newSession.Moderated,
 	})
 }
 
+// questionResponse is a question as one participant sees it: without the
+// voter lists, but with whether and which way that participant has voted.
+type questionResponse struct {
+	models.Question
+	HasVoted bool `json:"hasVoted"`
+	MyVote   int  `json:"myVote,omitempty"` // 1 or -1 when HasVoted
+}
+
+// pendingQuestion is a question awaiting moderation as announced to the
+// admins. The submitter's IP stays private, but HasSubmitter tells whether the
+// submitter can be banned.
+type pendingQuestion struct {
+	models.Question
+	HasSubmitter bool `json:"hasSubmitter"`
+}
+
+// questionFor returns q as seen by the participant whose vote on it is vote.
+func questionFor(q models.Question, vote int) questionResponse {
+	if q.Status == "" {
+		q.Status = models.QuestionOpen
+	}
+	return questionResponse{Question: q, HasVoted: vote != 0, MyVote: vote}
+}
+
+// statusOrder is the display order of question statuses.
+var statusOrder = map[models.QuestionStatus]int{
+	models.QuestionPinned:   0,
+	models.QuestionOpen:     1,
+	"":                      1,
+	models.QuestionAnswered: 2,
+	models.QuestionHidden:   3,
+	models.QuestionPending:  4,
+}
+
+// orderQuestions sorts questions for display: pinned first, then open ones,
+// then answered ones, each by votes. Hidden and pending ones, shown only to
+// the admin, come last.
+func orderQuestions(questions []models.Question) {
+	sort.SliceStable(questions, func(i, j int) bool {
+		si, sj := statusOrder[questions[i].Status], statusOrder[questions[j].Status]
+		if si != sj {
+			return si < sj
+		}
+		return questions[i].Votes > questions[j].Votes
+	})
+}
+
+// questionsFor returns the questions as seen by the participant userID.
+func questionsFor(questions []models.Question, userID string) []questionResponse {
+	views := make([]questionResponse, len(questions))
+	for i, q := range questions {
+		vote := 0
+		if userID != "" {
+			vote = q.VoteOf(userID)
+		}
+		views[i] = questionFor(q, vote)
+	}
+	return views
+}
+
+// sessionView is an existing session as shown to one caller, without the
+// admin token. It is the GET response and the payload of SNAPSHOT events.
+type sessionView struct {
+	SessionID    string            `json:"sessionId"`
+	SessionTitle string            `json:"sessionTitle"`
+	IsActive     bool              `json:"isActive"`
+	Version      int64             `json:"version"`
+	CreatedAt    time.Time         `json:"createdAt"`
+	ExpiresAt    time.Time         `json:"expiresAt"`
+	VotingMode   models.VotingMode `json:"votingMode"`
+	VoteBudget   int               `json:"voteBudget"`
+	Moderated    bool              `json:"moderated"`
+	// Participants is the number of open WebSocket connections. It is
+	// not covered by the ETag; clients follow it through PRESENCE events.
+	Participants int `json:"participants"`
+	// RemainingVotes is the caller's unused vote budget, in budget sessions.
+	RemainingVotes *int               `json:"remainingVotes,omitempty"`
+	Questions      []questionResponse `json:"questions"`
+}
+
+// sessionView builds the view of sessionData for the user with userID, who may
+// be the admin. Hidden and pending questions are only shown to the admin.
+func (a *API) sessionView(sessionData *models.SessionData, userID string, isAdmin bool) sessionView {
+	questions := sessionData.Questions
+	if !isAdmin {
+		questions = slices.DeleteFunc(questions, func(q models.Question) bool {
+			return !q.Visible()
+		})
+	}
+	orderQuestions(questions)
+
+	return sessionView{
+		SessionID:      sessionData.SessionID,
+		SessionTitle:   sessionData.SessionTitle,
+		IsActive:       sessionData.IsActive,
+		Version:        sessionData.Version,
+		CreatedAt:      sessionData.CreatedAt,
+		ExpiresAt:      sessionData.ExpiresAt,
+		VotingMode:     votingMode(sessionData),
+		VoteBudget:     sessionData.VoteBudget,
+		Moderated:      sessionData.Moderated,
+		Participants:   a.participants(sessionData.SessionID),
+		RemainingVotes: remainingVotes(sessionData, userID),
+		Questions:      questionsFor(questions, userID),
+	}
+}
+
 // GetSessionHandler retrieves the full session data (excluding sensitive info).
 // GET /api/session/{session_id}
 func (a *API) GetSessionHandler(w http.ResponseWriter, r *http.Request) {
@@ -230,6 +486,15 @@ func (a *API) GetSessionHandler(w http.ResponseWriter, r *http.Request) {
 	sessionData, err := a.Storer.LoadSessionData(r.Context(), sessionID)
 	if err != nil {
 		if isNotFoundError(err) {
+			// An ended session's link is not reused while its archive is kept.
+			if _, archiveErr := a.Storer.LoadArchivedSession(r.Context(), sessionID); archiveErr == nil {
+				http.Error(w, "Session has ended", http.StatusGone)
+				return
+			} else if !isNotFoundError(archiveErr) {
+				http.Error(w, "Failed to load session", http.StatusInternalServerError)
+				return
+			}
+
 			a.getUserSessionID(w, r) // Ensure creator also gets a voting cookie
 
 			// Determine language from the Accept-Language header
@@ -241,7 +506,7 @@ func (a *API) GetSessionHandler(w http.ResponseWriter, r *http.Request) {
 
 			sessionTitle := deslugify(sessionID, lang)
 
-			newSession, createErr := a.createSessionWithRetry(r.Context(), sessionID, sessionTitle)
+			newSession, createErr := a.createSessionWithRetry(r.Context(), newSessionData(sessionID, sessionTitle, a.SessionTTL))
 			if createErr != nil {
 				http.Error(w, "Failed to create session", http.StatusInternalServerError)
 				return
@@ -257,7 +522,11 @@ func (a *API) GetSessionHandler(w http.ResponseWriter, r *http.Request) {
 				"adminToken":   newSession.AdminToken,
 				"isActive":     newSession.IsActive,
 				"createdAt":    newSession.CreatedAt,
-				"questions":    newSession.Questions,
+				"expiresAt":    newSession.ExpiresAt,
+				"votingMode":   newSession.VotingMode,
+				"voteBudget":   newSession.VoteBudget,
+				"moderated":    newSession.Moderated,
+				"questions":    questionsFor(newSession.Questions, ""),
 			})
 			return
 		}
@@ -267,25 +536,28 @@ func (a *API) GetSessionHandler(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Session existed, or was created concurrently and is now loaded.
-	sort.Slice(sessionData.Questions, func(i, j int) bool {
-		return sessionData.Questions[i].Votes > sessionData.Questions[j].Votes
-	})
+	// The version doubles as an ETag so clients can revalidate cheaply; the
+	// body also depends on the caller's cookie through hasVoted, and on
+	// whether they are the admin through hidden questions.
+	etag := sessionETag(sessionData.Version)
+	w.Header().Set("ETag", etag)
+	w.Header().Set("Vary", "Cookie, Authorization")
+	if etagMatches(r.Header.Get("If-None-Match"), etag) {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
 
-	// Omit AdminToken for security on normal GETs of existing sessions.
-	response := struct {
-		SessionID    string            `json:"sessionId"`
-		SessionTitle string            `json:"sessionTitle"`
-		IsActive     bool              `json:"isActive"`
-		CreatedAt    time.Time         `json:"createdAt"`
-		Questions    []models.Question `json:"questions"`
-	}{
-		SessionID:    sessionData.SessionID,
-		SessionTitle: sessionData.SessionTitle,
-		IsActive:     sessionData.IsActive,
-		CreatedAt:    sessionData.CreatedAt,
-		Questions:    sessionData.Questions,
+	providedToken := strings.TrimPrefix(r.Header.Get(authHeader), "Bearer ")
+	isAdmin := sessionData.AdminToken == providedToken && providedToken != ""
+
+	var userID string
+	if cookie, err := r.Cookie(userSessionIDCookie); err == nil {
+		userID = cookie.Value
 	}
 
+	// Omit AdminToken for security on normal GETs of existing sessions.
+	response := a.sessionView(sessionData, userID, isAdmin)
+
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(response)
 }
@@ -303,7 +575,7 @@ func (a *API) SubmitQuestionHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if len(submission.Text) > 500 {
+	if len(submission.Text) > maxQuestionLength {
 		http.Error(w, "Question exceeds maximum length of 500 characters", http.StatusBadRequest)
 		return
 	}
@@ -323,11 +595,6 @@ func (a *API) SubmitQuestionHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if len(sessionData.Questions) >= maxQuestionsPerSession {
-		http.Error(w, "Session has reached the maximum number of questions", http.StatusForbidden)
-		return
-	}
-
 	clientIP := getClientIP(r)
 	for _, banned := range sessionData.BannedIPs {
 		if banned == clientIP {
@@ -342,49 +609,197 @@ func (a *API) SubmitQuestionHandler(w http.ResponseWriter, r *http.Request) {
 		Votes:       0,
 		Voters:      []string{},
 		SubmitterIP: clientIP,
+		CreatedAt:   time.Now().UTC().Truncate(time.Second),
+		Status:      models.QuestionOpen,
+	}
+	if sessionData.Moderated {
+		newQuestion.Status = models.QuestionPending
 	}
 
-	sessionData.Questions = append(sessionData.Questions, newQuestion)
+	if err := a.Storer.AddQuestion(r.Context(), sessionID, newQuestion, maxQuestionsPerSession); err != nil {
+		switch {
+		case errors.Is(err, storage.ErrLimitReached):
+			http.Error(w, "Session has reached the maximum number of questions", http.StatusForbidden)
+		case isNotFoundError(err):
+			http.Error(w, "Session not found", http.StatusNotFound)
+		default:
+			http.Error(w, "Failed to save question", http.StatusInternalServerError)
+		}
+		return
+	}
 
-	if err := a.Storer.UpdateSessionData(r.Context(), sessionData); err != nil {
-		http.Error(w, "Failed to save question", http.StatusInternalServerError)
+	// In moderated sessions only the admin hears of the question until it is
+	// approved.
+	if newQuestion.Status == models.QuestionPending {
+		a.broadcastToAdmins(sessionID, "QUESTION_PENDING", pendingQuestion{Question: newQuestion, HasSubmitter: newQuestion.SubmitterIP != ""})
+		w.WriteHeader(http.StatusAccepted)
+		json.NewEncoder(w).Encode(newQuestion)
 		return
 	}
 
 	// Broadcast update
-	if a.Hub != nil {
-		event := map[string]interface{}{
-			"type":    "QUESTION_ADDED",
-			"payload": newQuestion,
+	a.broadcast(sessionID, "QUESTION_ADDED", newQuestion)
+
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(newQuestion)
+}
+
+// importEntry is one question of an import, with optional pre-seeded votes.
+type importEntry struct {
+	Text  string `json:"text"`
+	Votes int    `json:"votes"`
+}
+
+// UnmarshalJSON accepts either a plain string or a {"text", "votes"} object.
+func (e *importEntry) UnmarshalJSON(b []byte) error {
+	if err := json.Unmarshal(b, &e.Text); err == nil {
+		return nil
+	}
+	type plain importEntry
+	return json.Unmarshal(b, (*plain)(e))
+}
+
+// parseImport reads the questions of an import body: a JSON array, or CSV
+// with the text in the first column and optional votes in the second. A CSV
+// header row starting with "text" or "question" is skipped.
+func parseImport(r *http.Request) ([]importEntry, error) {
+	if !strings.HasPrefix(r.Header.Get("Content-Type"), "text/csv") {
+		var entries []importEntry
+		if err := json.NewDecoder(r.Body).Decode(&entries); err != nil {
+			return nil, errors.New("expected a JSON array of questions")
 		}
-		if msg, err := json.Marshal(event); err == nil {
-			a.Hub.Broadcast(sessionID, msg)
+		return entries, nil
+	}
+
+	cr := csv.NewReader(r.Body)
+	cr.FieldsPerRecord = -1
+	records, err := cr.ReadAll()
+	if err != nil {
+		return nil, fmt.Errorf("invalid CSV: %w", err)
+	}
+	if len(records) > 0 {
+		switch strings.ToLower(strings.TrimSpace(records[0][0])) {
+		case "text", "question":
+			records = records[1:]
 		}
 	}
+	entries := make([]importEntry, len(records))
+	for i, record := range records {
+		entries[i].Text = record[0]
+		if len(record) > 1 && strings.TrimSpace(record[1]) != "" {
+			votes, err := strconv.Atoi(strings.TrimSpace(record[1]))
+			if err != nil {
+				return nil, fmt.Errorf("question %d: invalid vote count %q", i+1, record[1])
+			}
+			entries[i].Votes = votes
+		}
+	}
+	return entries, nil
+}
 
+// ImportQuestionsHandler lets the admin add prepared questions in one go, as
+// a JSON array of strings or {"text", "votes"} objects, or as CSV. Every
+// question is checked like a submitted one and they are saved all together or
+// not at all. Clients receive a single QUESTIONS_IMPORTED event.
+// POST /api/session/{session_id}/questions/import
+func (a *API) ImportQuestionsHandler(w http.ResponseWriter, r *http.Request) {
+	sessionID := r.PathValue("session_id")
+
+	authHeader := r.Header.Get(authHeader)
+	providedToken := strings.TrimPrefix(authHeader, "Bearer ")
+
+	sessionData, err := a.Storer.LoadSessionData(r.Context(), sessionID)
+	if err != nil {
+		if isNotFoundError(err) {
+			http.Error(w, "Session not found", http.StatusNotFound)
+			return
+		}
+		http.Error(w, "Failed to load session", http.StatusInternalServerError)
+		return
+	}
+
+	if sessionData.AdminToken != providedToken || providedToken == "" {
+		http.Error(w, "Unauthorized", http.StatusForbidden)
+		return
+	}
+
+	r.Body = http.MaxBytesReader(w, r.Body, maxImportBodyBytes)
+	entries, err := parseImport(r)
+	if err != nil {
+		http.Error(w, "Invalid request body: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	if len(entries) == 0 {
+		http.Error(w, "No questions to import", http.StatusBadRequest)
+		return
+	}
+	for i, e := range entries {
+		switch {
+		case strings.TrimSpace(e.Text) == "":
+			http.Error(w, fmt.Sprintf("Question %d is empty", i+1), http.StatusBadRequest)
+			return
+		case len(e.Text) > maxQuestionLength:
+			http.Error(w, fmt.Sprintf("Question %d exceeds maximum length of 500 characters", i+1), http.StatusBadRequest)
+			return
+		case e.Votes < 0:
+			http.Error(w, fmt.Sprintf("Question %d has a negative vote count", i+1), http.StatusBadRequest)
+			return
+		}
+	}
+
+	now := time.Now().UTC().Truncate(time.Second)
+	questions := make([]models.Question, len(entries))
+	for i, e := range entries {
+		questions[i] = models.Question{
+			ID:        uuid.New().String(),
+			Text:      e.Text,
+			Votes:     e.Votes,
+			Voters:    []string{},
+			CreatedAt: now,
+			Status:    models.QuestionOpen,
+		}
+	}
+
+	if err := a.Storer.AddQuestions(r.Context(), sessionID, questions, maxQuestionsPerSession); err != nil {
+		switch {
+		case errors.Is(err, storage.ErrLimitReached):
+			http.Error(w, "Import would exceed the maximum number of questions", http.StatusForbidden)
+		case isNotFoundError(err):
+			http.Error(w, "Session not found", http.StatusNotFound)
+		default:
+			http.Error(w, "Failed to save questions", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	// Broadcast all imported questions as one update
+	a.broadcast(sessionID, "QUESTIONS_IMPORTED", map[string][]models.Question{"questions": questions})
+
+	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusCreated)
-	json.NewEncoder(w).Encode(newQuestion)
+	json.NewEncoder(w).Encode(questions)
 }
 
-// VoteQuestionHandler increments the vote count for a question.
-// PUT /api/session/{session_id}/questions/{question_id}/vote
-func (a *API) VoteQuestionHandler(w http.ResponseWriter, r *http.Request) {
+// checkVoteRequest validates a vote or unvote request: the caller needs a
+// user cookie, and the session must exist and be open. It writes the error
+// response and returns ok == false if any check fails.
+func (a *API) checkVoteRequest(w http.ResponseWriter, r *http.Request) (sessionData *models.SessionData, questionID, userID string, ok bool) {
 	sessionID := r.PathValue("session_id")
-	questionID := r.PathValue("question_id")
+	questionID = r.PathValue("question_id")
 
 	cookie, err := r.Cookie(userSessionIDCookie)
 	if err != nil {
 		http.Error(w, "Forbidden", http.StatusForbidden)
 		return
 	}
-	userID := cookie.Value
+	userID = cookie.Value
 
 	if _, err := uuid.Parse(questionID); err != nil {
 		http.Error(w, "Invalid question ID format", http.StatusBadRequest)
 		return
 	}
 
-	sessionData, err := a.Storer.LoadSessionData(r.Context(), sessionID)
+	sessionData, err = a.Storer.LoadSessionData(r.Context(), sessionID)
 	if err != nil {
 		if isNotFoundError(err) {
 			http.Error(w, "Session not found", http.StatusNotFound)
@@ -399,46 +814,125 @@ func (a *API) VoteQuestionHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	for i, q := range sessionData.Questions {
-		if q.ID == questionID {
-			for _, voterID := range q.Voters {
-				if voterID == userID {
-					http.Error(w, "Already voted on this question in this session", http.StatusForbidden)
-					return
-				}
-			}
+	// Hidden and pending questions are not there as far as participants
+	// are concerned.
+	if slices.ContainsFunc(sessionData.Questions, func(q models.Question) bool {
+		return q.ID == questionID && !q.Visible()
+	}) {
+		http.Error(w, "Question not found", http.StatusNotFound)
+		return
+	}
+	return sessionData, questionID, userID, true
+}
+
+// voteResponse is the response to a vote or unvote: the question as the
+// caller now sees it and, in budget sessions, how many votes they have left.
+type voteResponse struct {
+	questionResponse
+	RemainingVotes *int `json:"remainingVotes,omitempty"`
+}
 
-			sessionData.Questions[i].Votes++
-			sessionData.Questions[i].Voters = append(sessionData.Questions[i].Voters, userID)
+// newVoteResponse builds the response to a vote that left q with the caller's
+// vote on it at vote. sessionData is the session as loaded before the vote.
+func newVoteResponse(sessionData *models.SessionData, q *models.Question, userID string, vote int) voteResponse {
+	for i := range sessionData.Questions {
+		if sessionData.Questions[i].ID == q.ID {
+			sessionData.Questions[i] = *q
+		}
+	}
+	return voteResponse{
+		questionResponse: questionFor(*q, vote),
+		RemainingVotes:   remainingVotes(sessionData, userID),
+	}
+}
 
-			if err := a.Storer.UpdateSessionData(r.Context(), sessionData); err != nil {
-				http.Error(w, "Failed to record vote", http.StatusInternalServerError)
-				return
-			}
+// VoteQuestionHandler votes on a question. The optional body
+// {"direction": "down"} casts a downvote, in up/down sessions only.
+// PUT /api/session/{session_id}/questions/{question_id}/vote
+func (a *API) VoteQuestionHandler(w http.ResponseWriter, r *http.Request) {
+	sessionData, questionID, userID, ok := a.checkVoteRequest(w, r)
+	if !ok {
+		return
+	}
+	sessionID := sessionData.SessionID
 
-			// Broadcast update
-			if a.Hub != nil {
-				event := map[string]interface{}{
-					"type":    "VOTE_UPDATED",
-					"payload": sessionData.Questions[i],
-				}
-				if msg, err := json.Marshal(event); err == nil {
-					a.Hub.Broadcast(sessionID, msg)
-				}
-			}
+	r.Body = http.MaxBytesReader(w, r.Body, maxRequestBodyBytes)
+	var req struct {
+		Direction string `json:"direction"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil && !errors.Is(err, io.EOF) {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
 
-			w.WriteHeader(http.StatusOK)
-			json.NewEncoder(w).Encode(sessionData.Questions[i])
+	vote, addVote := 1, a.Storer.AddVote
+	switch req.Direction {
+	case "", "up":
+	case "down":
+		if votingMode(sessionData) != models.VotingModeUpDown {
+			http.Error(w, "Downvotes are not enabled in this session", http.StatusBadRequest)
 			return
 		}
+		vote, addVote = -1, a.Storer.AddDownvote
+	default:
+		http.Error(w, "Invalid vote direction", http.StatusBadRequest)
+		return
+	}
+
+	votedQuestion, err := addVote(r.Context(), sessionID, questionID, userID)
+	if err != nil {
+		switch {
+		case errors.Is(err, storage.ErrAlreadyVoted):
+			http.Error(w, "Already voted on this question in this session", http.StatusForbidden)
+		case errors.Is(err, storage.ErrNoVotesLeft):
+			http.Error(w, "No votes left in this session", http.StatusForbidden)
+		case isNotFoundError(err):
+			http.Error(w, "Question not found", http.StatusNotFound)
+		default:
+			http.Error(w, "Failed to record vote", http.StatusInternalServerError)
+		}
+		return
 	}
 
-	http.Error(w, "Question not found", http.StatusNotFound)
+	// Broadcast the new count only; who voted stays private
+	a.broadcastVotes(sessionID, votedQuestion)
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(newVoteResponse(sessionData, votedQuestion, userID, vote))
 }
 
-// DeleteQuestionHandler allows the admin to delete a question.
-// DELETE /api/session/{session_id}/questions/{question_id}
-func (a *API) DeleteQuestionHandler(w http.ResponseWriter, r *http.Request) {
+// UnvoteQuestionHandler retracts the caller's vote on a question.
+// DELETE /api/session/{session_id}/questions/{question_id}/vote
+func (a *API) UnvoteQuestionHandler(w http.ResponseWriter, r *http.Request) {
+	sessionData, questionID, userID, ok := a.checkVoteRequest(w, r)
+	if !ok {
+		return
+	}
+	sessionID := sessionData.SessionID
+
+	unvotedQuestion, err := a.Storer.RemoveVote(r.Context(), sessionID, questionID, userID)
+	if err != nil {
+		switch {
+		case errors.Is(err, storage.ErrNotVoted):
+			http.Error(w, "Not voted on this question in this session", http.StatusForbidden)
+		case isNotFoundError(err):
+			http.Error(w, "Question not found", http.StatusNotFound)
+		default:
+			http.Error(w, "Failed to remove vote", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	// Broadcast the new count only; who voted stays private
+	a.broadcastVotes(sessionID, unvotedQuestion)
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(newVoteResponse(sessionData, unvotedQuestion, userID, 0))
+}
+
+// UpdateQuestionHandler allows the admin to change a question's status.
+// PATCH /api/session/{session_id}/questions/{question_id}
+func (a *API) UpdateQuestionHandler(w http.ResponseWriter, r *http.Request) {
 	sessionID := r.PathValue("session_id")
 	questionID := r.PathValue("question_id")
 
@@ -460,39 +954,161 @@ func (a *API) DeleteQuestionHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	found := false
-	var updatedQuestions []models.Question
-	for _, q := range sessionData.Questions {
-		if q.ID == questionID {
-			found = true
-		} else {
-			updatedQuestions = append(updatedQuestions, q)
+	r.Body = http.MaxBytesReader(w, r.Body, maxRequestBodyBytes)
+	var update models.QuestionUpdate
+	if err := json.NewDecoder(r.Body).Decode(&update); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if !update.Status.Valid() || update.Status == models.QuestionPending {
+		http.Error(w, "Invalid question status", http.StatusBadRequest)
+		return
+	}
+	// Pending questions leave the queue through approve or reject only, so
+	// that participants learn of them exactly once.
+	if slices.ContainsFunc(sessionData.Questions, func(q models.Question) bool {
+		return q.ID == questionID && q.Status == models.QuestionPending
+	}) {
+		http.Error(w, "Question is awaiting moderation", http.StatusConflict)
+		return
+	}
+
+	updatedQuestion, err := a.Storer.SetQuestionStatus(r.Context(), sessionID, questionID, update.Status)
+	if err != nil {
+		if isNotFoundError(err) {
+			http.Error(w, "Question not found", http.StatusNotFound)
+			return
+		}
+		http.Error(w, "Failed to update question", http.StatusInternalServerError)
+		return
+	}
+
+	// Broadcast update. Participants don't get to read a question that was
+	// just hidden from them, only to drop it.
+	if updatedQuestion.Visible() {
+		a.broadcast(sessionID, "QUESTION_UPDATED", updatedQuestion)
+	} else {
+		a.broadcastToAdmins(sessionID, "QUESTION_UPDATED", updatedQuestion)
+		a.broadcastToParticipants(sessionID, "QUESTION_HIDDEN", map[string]string{"id": questionID})
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(questionFor(*updatedQuestion, 0))
+}
+
+// ApproveQuestionHandler publishes a pending question of a moderated session.
+// POST /api/session/{session_id}/questions/{question_id}/approve
+func (a *API) ApproveQuestionHandler(w http.ResponseWriter, r *http.Request) {
+	a.moderateQuestion(w, r, true)
+}
+
+// RejectQuestionHandler deletes a pending question of a moderated session
+// without participants ever seeing it.
+// POST /api/session/{session_id}/questions/{question_id}/reject
+func (a *API) RejectQuestionHandler(w http.ResponseWriter, r *http.Request) {
+	a.moderateQuestion(w, r, false)
+}
+
+// moderateQuestion implements ApproveQuestionHandler and RejectQuestionHandler.
+func (a *API) moderateQuestion(w http.ResponseWriter, r *http.Request, approve bool) {
+	sessionID := r.PathValue("session_id")
+	questionID := r.PathValue("question_id")
+
+	authHeader := r.Header.Get(authHeader)
+	providedToken := strings.TrimPrefix(authHeader, "Bearer ")
+
+	sessionData, err := a.Storer.LoadSessionData(r.Context(), sessionID)
+	if err != nil {
+		if isNotFoundError(err) {
+			http.Error(w, "Session not found", http.StatusNotFound)
+			return
 		}
+		http.Error(w, "Failed to load session", http.StatusInternalServerError)
+		return
+	}
+
+	if sessionData.AdminToken != providedToken || providedToken == "" {
+		http.Error(w, "Unauthorized", http.StatusForbidden)
+		return
 	}
 
-	if !found {
+	i := slices.IndexFunc(sessionData.Questions, func(q models.Question) bool { return q.ID == questionID })
+	if i < 0 {
 		http.Error(w, "Question not found", http.StatusNotFound)
 		return
 	}
+	if sessionData.Questions[i].Status != models.QuestionPending {
+		http.Error(w, "Question is not awaiting moderation", http.StatusConflict)
+		return
+	}
 
-	sessionData.Questions = updatedQuestions
+	if !approve {
+		if err := a.Storer.DeleteQuestion(r.Context(), sessionID, questionID); err != nil {
+			if isNotFoundError(err) {
+				http.Error(w, "Question not found", http.StatusNotFound)
+				return
+			}
+			http.Error(w, "Failed to reject question", http.StatusInternalServerError)
+			return
+		}
+		a.broadcastToAdmins(sessionID, "QUESTION_REJECTED", map[string]string{"id": questionID})
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
 
-	if err := a.Storer.UpdateSessionData(r.Context(), sessionData); err != nil {
-		http.Error(w, "Failed to delete question", http.StatusInternalServerError)
+	approvedQuestion, err := a.Storer.SetQuestionStatus(r.Context(), sessionID, questionID, models.QuestionOpen)
+	if err != nil {
+		if isNotFoundError(err) {
+			http.Error(w, "Question not found", http.StatusNotFound)
+			return
+		}
+		http.Error(w, "Failed to approve question", http.StatusInternalServerError)
 		return
 	}
 
-	// Broadcast update
-	if a.Hub != nil {
-		event := map[string]interface{}{
-			"type":    "QUESTION_DELETED",
-			"payload": map[string]string{"id": questionID},
+	// Participants see the question for the first time
+	a.broadcast(sessionID, "QUESTION_ADDED", approvedQuestion)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(questionFor(*approvedQuestion, 0))
+}
+
+// DeleteQuestionHandler allows the admin to delete a question.
+// DELETE /api/session/{session_id}/questions/{question_id}
+func (a *API) DeleteQuestionHandler(w http.ResponseWriter, r *http.Request) {
+	sessionID := r.PathValue("session_id")
+	questionID := r.PathValue("question_id")
+
+	authHeader := r.Header.Get(authHeader)
+	providedToken := strings.TrimPrefix(authHeader, "Bearer ")
+
+	sessionData, err := a.Storer.LoadSessionData(r.Context(), sessionID)
+	if err != nil {
+		if isNotFoundError(err) {
+			http.Error(w, "Session not found", http.StatusNotFound)
+			return
 		}
-		if msg, err := json.Marshal(event); err == nil {
-			a.Hub.Broadcast(sessionID, msg)
+		http.Error(w, "Failed to load session", http.StatusInternalServerError)
+		return
+	}
+
+	if sessionData.AdminToken != providedToken || providedToken == "" {
+		http.Error(w, "Unauthorized", http.StatusForbidden)
+		return
+	}
+
+	if err := a.Storer.DeleteQuestion(r.Context(), sessionID, questionID); err != nil {
+		if isNotFoundError(err) {
+			http.Error(w, "Question not found", http.StatusNotFound)
+			return
 		}
+		http.Error(w, "Failed to delete question", http.StatusInternalServerError)
+		return
 	}
 
+	// Broadcast update
+	a.broadcast(sessionID, "QUESTION_DELETED", map[string]string{"id": questionID})
+
 	w.WriteHeader(http.StatusNoContent)
 }
 
@@ -519,18 +1135,251 @@ func (a *API) EndSessionHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if err := a.Storer.DeleteSessionData(r.Context(), sessionID); err != nil {
+	if a.ArchiveRetention > 0 {
+		err = a.Storer.ArchiveSession(r.Context(), sessionID, time.Now().Add(a.ArchiveRetention))
+		if err != nil && !isNotFoundError(err) {
+			http.Error(w, "Failed to archive session", http.StatusInternalServerError)
+			return
+		}
+	} else if err := a.Storer.DeleteSessionData(r.Context(), sessionID); err != nil {
 		http.Error(w, "Failed to delete session file", http.StatusInternalServerError)
 		return
 	}
 
 	// Broadcast that the session ended
-	if a.Hub != nil {
-		event := map[string]interface{}{
-			"type": "SESSION_ENDED",
+	a.broadcast(sessionID, "SESSION_ENDED", nil)
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// ArchiveHandler returns the read-only archive of an ended session to its admin.
+// GET /api/session/{session_id}/archive
+func (a *API) ArchiveHandler(w http.ResponseWriter, r *http.Request) {
+	sessionID := r.PathValue("session_id")
+
+	authHeader := r.Header.Get(authHeader)
+	providedToken := strings.TrimPrefix(authHeader, "Bearer ")
+
+	archived, err := a.Storer.LoadArchivedSession(r.Context(), sessionID)
+	if err != nil {
+		if isNotFoundError(err) {
+			http.Error(w, "Archived session not found", http.StatusNotFound)
+			return
 		}
-		if msg, err := json.Marshal(event); err == nil {
-			a.Hub.Broadcast(sessionID, msg)
+		http.Error(w, "Failed to load archived session", http.StatusInternalServerError)
+		return
+	}
+
+	if archived.Session.AdminToken != providedToken || providedToken == "" {
+		http.Error(w, "Unauthorized", http.StatusForbidden)
+		return
+	}
+
+	questions := archived.Session.Questions
+	sort.SliceStable(questions, func(i, j int) bool {
+		return questions[i].Votes > questions[j].Votes
+	})
+
+	response := struct {
+		SessionID    string            `json:"sessionId"`
+		SessionTitle string            `json:"sessionTitle"`
+		CreatedAt    time.Time         `json:"createdAt"`
+		ArchivedAt   time.Time         `json:"archivedAt"`
+		RetainUntil  time.Time         `json:"retainUntil"`
+		Questions    []models.Question `json:"questions"`
+	}{
+		SessionID:    archived.Session.SessionID,
+		SessionTitle: archived.Session.SessionTitle,
+		CreatedAt:    archived.Session.CreatedAt,
+		ArchivedAt:   archived.ArchivedAt,
+		RetainUntil:  archived.RetainUntil,
+		Questions:    questions,
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+// ExportSessionHandler lets the admin download the session's questions ranked
+// by votes, as JSON (the default), CSV or Markdown. Ended sessions are exported
+// from their archive.
+// GET /api/session/{session_id}/export?format=json|csv|md
+func (a *API) ExportSessionHandler(w http.ResponseWriter, r *http.Request) {
+	sessionID := r.PathValue("session_id")
+
+	authHeader := r.Header.Get(authHeader)
+	providedToken := strings.TrimPrefix(authHeader, "Bearer ")
+
+	format := r.URL.Query().Get("format")
+	if format == "" {
+		format = "json"
+	}
+	exporter, ok := export.Lookup(format)
+	if !ok {
+		http.Error(w, "Unsupported export format, use one of: "+strings.Join(export.Formats(), ", "), http.StatusBadRequest)
+		return
+	}
+
+	sessionData, err := a.Storer.LoadSessionData(r.Context(), sessionID)
+	if isNotFoundError(err) {
+		var archived *models.ArchivedSession
+		if archived, err = a.Storer.LoadArchivedSession(r.Context(), sessionID); err == nil {
+			sessionData = &archived.Session
+		}
+	}
+	if err != nil {
+		if isNotFoundError(err) {
+			http.Error(w, "Session not found", http.StatusNotFound)
+			return
+		}
+		http.Error(w, "Failed to load session", http.StatusInternalServerError)
+		return
+	}
+
+	if sessionData.AdminToken != providedToken || providedToken == "" {
+		http.Error(w, "Unauthorized", http.StatusForbidden)
+		return
+	}
+
+	// Render the whole export first, so that a failure is still a 500
+	// rather than a truncated download.
+	report := export.NewReport(sessionData, time.Now().UTC().Truncate(time.Second))
+	var buf bytes.Buffer
+	if err := exporter.Export(&buf, report); err != nil {
+		log.Printf("Export of session %q as %s failed: %v", sessionID, format, err)
+		http.Error(w, "Failed to export session", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", exporter.ContentType())
+	w.Header().Set("Content-Disposition", mime.FormatMediaType("attachment",
+		map[string]string{"filename": sessionID + "." + exporter.Extension()}))
+	buf.WriteTo(w)
+}
+
+// ExtendSessionHandler allows the admin to push back the session's expiry.
+// The optional "duration" (e.g. "48h") defaults to the configured session TTL
+// and is added to the later of now and the current expiry.
+// POST /api/session/{session_id}/extend
+func (a *API) ExtendSessionHandler(w http.ResponseWriter, r *http.Request) {
+	sessionID := r.PathValue("session_id")
+
+	authHeader := r.Header.Get(authHeader)
+	providedToken := strings.TrimPrefix(authHeader, "Bearer ")
+
+	r.Body = http.MaxBytesReader(w, r.Body, maxRequestBodyBytes)
+	var req struct {
+		Duration string `json:"duration"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil && !errors.Is(err, io.EOF) {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	extension := a.SessionTTL
+	if req.Duration != "" {
+		d, err := time.ParseDuration(req.Duration)
+		if err != nil || d <= 0 || d > maxSessionExtension {
+			http.Error(w, "Invalid duration", http.StatusBadRequest)
+			return
+		}
+		extension = d
+	}
+
+	sessionData, err := a.Storer.LoadSessionData(r.Context(), sessionID)
+	if err != nil {
+		if isNotFoundError(err) {
+			http.Error(w, "Session not found", http.StatusNotFound)
+			return
+		}
+		http.Error(w, "Failed to load session", http.StatusInternalServerError)
+		return
+	}
+
+	if sessionData.AdminToken != providedToken || providedToken == "" {
+		http.Error(w, "Unauthorized", http.StatusForbidden)
+		return
+	}
+
+	updated, err := storage.Mutate(r.Context(), a.Storer, sessionID, func(data *models.SessionData) error {
+		from := time.Now()
+		if data.ExpiresAt.After(from) {
+			from = data.ExpiresAt
+		}
+		data.ExpiresAt = from.Add(extension)
+		return nil
+	})
+	if err != nil {
+		if isNotFoundError(err) {
+			http.Error(w, "Session not found", http.StatusNotFound)
+			return
+		}
+		http.Error(w, "Failed to extend session", http.StatusInternalServerError)
+		return
+	}
+
+	payload := map[string]time.Time{"expiresAt": updated.ExpiresAt}
+	a.broadcast(sessionID, "SESSION_EXTENDED", payload)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(payload)
+}
+
+// CloseSessionHandler allows the admin to make the session read-only: its
+// questions stay visible but no new questions or votes are accepted.
+// POST /api/session/{session_id}/close
+func (a *API) CloseSessionHandler(w http.ResponseWriter, r *http.Request) {
+	a.setSessionActive(w, r, false)
+}
+
+// ReopenSessionHandler allows the admin to accept questions and votes again
+// after closing the session.
+// POST /api/session/{session_id}/reopen
+func (a *API) ReopenSessionHandler(w http.ResponseWriter, r *http.Request) {
+	a.setSessionActive(w, r, true)
+}
+
+// setSessionActive implements CloseSessionHandler and ReopenSessionHandler.
+// Clients are only notified when the state actually changes.
+func (a *API) setSessionActive(w http.ResponseWriter, r *http.Request, active bool) {
+	sessionID := r.PathValue("session_id")
+
+	authHeader := r.Header.Get(authHeader)
+	providedToken := strings.TrimPrefix(authHeader, "Bearer ")
+
+	sessionData, err := a.Storer.LoadSessionData(r.Context(), sessionID)
+	if err != nil {
+		if isNotFoundError(err) {
+			http.Error(w, "Session not found", http.StatusNotFound)
+			return
+		}
+		http.Error(w, "Failed to load session", http.StatusInternalServerError)
+		return
+	}
+
+	if sessionData.AdminToken != providedToken || providedToken == "" {
+		http.Error(w, "Unauthorized", http.StatusForbidden)
+		return
+	}
+
+	changed := false
+	_, err = storage.Mutate(r.Context(), a.Storer, sessionID, func(data *models.SessionData) error {
+		changed = data.IsActive != active
+		data.IsActive = active
+		return nil
+	})
+	if err != nil {
+		if isNotFoundError(err) {
+			http.Error(w, "Session not found", http.StatusNotFound)
+			return
+		}
+		http.Error(w, "Failed to update session", http.StatusInternalServerError)
+		return
+	}
+
+	if changed {
+		if active {
+			a.broadcast(sessionID, "SESSION_REOPENED", nil)
+		} else {
+			a.broadcast(sessionID, "SESSION_CLOSED", nil)
 		}
 	}
 
@@ -594,16 +1443,15 @@ func (a *API) BanIPHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	var targetIP string
-	for _, q := range sessionData.Questions {
-		if q.ID == req.QuestionID {
-			targetIP = q.SubmitterIP
-			break
-		}
+	i := slices.IndexFunc(sessionData.Questions, func(q models.Question) bool { return q.ID == req.QuestionID })
+	if i < 0 {
+		http.Error(w, "Question not found", http.StatusNotFound)
+		return
 	}
-
+	targetIP := sessionData.Questions[i].SubmitterIP
 	if targetIP == "" {
-		http.Error(w, "Question not found", http.StatusNotFound)
+		// Imported questions were not submitted by anyone.
+		http.Error(w, "Question has no submitter to ban", http.StatusUnprocessableEntity)
 		return
 	}
 
@@ -619,44 +1467,101 @@ func (a *API) BanIPHandler(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
-	sessionData.BannedIPs = append(sessionData.BannedIPs, targetIP)
-
-	var removedIDs []string
-	remaining := []models.Question{}
-	for _, q := range sessionData.Questions {
-		if q.SubmitterIP == targetIP {
-			removedIDs = append(removedIDs, q.ID)
-		} else {
-			remaining = append(remaining, q)
+	removedIDs, err := a.Storer.BanSubmitter(r.Context(), sessionID, targetIP)
+	if err != nil {
+		if isNotFoundError(err) {
+			http.Error(w, "Session not found", http.StatusNotFound)
+			return
 		}
-	}
-	sessionData.Questions = remaining
-
-	if err := a.Storer.UpdateSessionData(r.Context(), sessionData); err != nil {
 		http.Error(w, "Failed to ban submitter", http.StatusInternalServerError)
 		return
 	}
 
-	if a.Hub != nil {
-		event := map[string]interface{}{
-			"type":    "IP_BANNED",
-			"payload": map[string]interface{}{"questionIds": removedIDs},
-		}
-		if msg, err := json.Marshal(event); err == nil {
-			a.Hub.Broadcast(sessionID, msg)
-		}
-	}
+	a.broadcast(sessionID, "IP_BANNED", map[string]interface{}{"questionIds": removedIDs})
 
 	w.WriteHeader(http.StatusNoContent)
 }
 
-// ServeWS handles WebSocket requests from the frontend.
+// connection describes the real-time client making request r to sessionData:
+// its role, from the admin token in the token query parameter or the
+// Authorization header, the seq it resumes from, and its snapshot.
+func (a *API) connection(r *http.Request, sessionData *models.SessionData) ws.Connection {
+	sessionID := sessionData.SessionID
+	isAdminToken := func(token string) bool {
+		return token != "" && token == sessionData.AdminToken
+	}
+	providedToken := r.URL.Query().Get("token")
+	if providedToken == "" {
+		providedToken = strings.TrimPrefix(r.Header.Get(authHeader), "Bearer ")
+	}
+	role := ws.RoleParticipant
+	if isAdminToken(providedToken) {
+		role = ws.RoleAdmin
+	}
+	var userID string
+	if cookie, err := r.Cookie(userSessionIDCookie); err == nil {
+		userID = cookie.Value
+	}
+
+	return ws.Connection{
+		SessionID:    sessionID,
+		Role:         role,
+		Authenticate: isAdminToken,
+		// An invalid lastSeq is treated as none.
+		LastEvent: ws.ParseCursor(r.URL.Query().Get("lastSeq")),
+		// The snapshot after an AUTH message is taken once the upgrade
+		// request is done, so it must outlive the request's context.
+		Snapshot: func(role ws.Role) (interface{}, error) {
+			sessionData, err := a.Storer.LoadSessionData(context.WithoutCancel(r.Context()), sessionID)
+			if err != nil {
+				return nil, err
+			}
+			return a.sessionView(sessionData, userID, role == ws.RoleAdmin), nil
+		},
+		Commands: a.commandRunner(r, sessionData),
+	}
+}
+
+// ServeWS handles WebSocket requests from the frontend. The admin token makes
+// the connection an admin one, which also receives admin-only events. It can be
+// sent at upgrade, in the Authorization header or, since browsers cannot set
+// headers on WebSocket requests, as the token query parameter; or later, as the
+// first message on the connection.
+//
+// A client that reconnects passes the epoch and seq of the last event it
+// received as the lastSeq query parameter, "<epoch>:<seq>", and is sent the
+// events it missed. Otherwise, or if they are no longer available, as on
+// another instance, it is first sent a SNAPSHOT event with the session as GET
+// returns it for the connection's role at upgrade.
 // GET /api/session/{session_id}/ws
 func (a *API) ServeWS(w http.ResponseWriter, r *http.Request) {
 	sessionID := r.PathValue("session_id")
 
 	// Ensure the session exists before allowing a websocket connection
-	_, err := a.Storer.LoadSessionData(r.Context(), sessionID)
+	sessionData, err := a.Storer.LoadSessionData(r.Context(), sessionID)
+	if err != nil {
+		if isNotFoundError(err) {
+			http.Error(w, "Session not found", http.StatusNotFound)
+			return
+		}
+		http.Error(w, "Failed to load session", http.StatusInternalServerError)
+		return
+	}
+
+	if a.Hub != nil {
+		a.Hub.ServeWS(w, r, a.connection(r, sessionData))
+	}
+}
+
+// ServeEvents streams the same events as ServeWS as Server-Sent Events, for
+// networks that block WebSocket upgrades. The admin token works as for
+// ServeWS, but only at connect time. A reconnecting EventSource resumes from
+// its Last-Event-ID header, or a client can pass the lastSeq query parameter.
+// GET /api/session/{session_id}/events
+func (a *API) ServeEvents(w http.ResponseWriter, r *http.Request) {
+	sessionID := r.PathValue("session_id")
+
+	sessionData, err := a.Storer.LoadSessionData(r.Context(), sessionID)
 	if err != nil {
 		if isNotFoundError(err) {
 			http.Error(w, "Session not found", http.StatusNotFound)
@@ -667,6 +1572,6 @@ func (a *API) ServeWS(w http.ResponseWriter, r *http.Request) {
 	}
 
 	if a.Hub != nil {
-		a.Hub.ServeWS(w, r, sessionID)
+		a.Hub.ServeSSE(w, r, a.connection(r, sessionData))
 	}
 }
@@ -1,18 +1,24 @@
 package handlers
 
 import (
+	"bufio"
 	"context"
 	"encoding/json"
 	"fmt"
 	"net/http"
 	"net/http/httptest"
+	"slices"
 	"strings"
+	"sync"
 	"testing"
+	"time"
 
 	"question-voting-app/internal/models"
 	"question-voting-app/internal/testutil"
 	"question-voting-app/internal/ws"
 
+	"github.com/alicebob/miniredis/v2"
+	"github.com/gorilla/websocket"
 	"golang.org/x/text/language"
 )
 
@@ -214,6 +220,45 @@ func TestCreateSessionHandler(t *testing.T) {
 			t.Fatalf("Expected status %d, got %d", http.StatusBadRequest, w.Code)
 		}
 	})
+
+	t.Run("VotingMode", func(t *testing.T) {
+		storer.Clear()
+		body := `{"sessionId": "budgeted", "votingMode": "budget", "voteBudget": 3}`
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest(http.MethodPost, "/api/session", strings.NewReader(body))
+
+		api.CreateSessionHandler(w, r)
+
+		if w.Code != http.StatusCreated {
+			t.Fatalf("Expected status %d, got %d", http.StatusCreated, w.Code)
+		}
+		session, err := storer.LoadSessionData(context.Background(), "budgeted")
+		if err != nil {
+			t.Fatalf("Session was not created: %v", err)
+		}
+		if session.VotingMode != models.VotingModeBudget || session.VoteBudget != 3 {
+			t.Errorf("Expected budget mode with 3 votes, got %q with %d", session.VotingMode, session.VoteBudget)
+		}
+	})
+
+	t.Run("InvalidVotingMode", func(t *testing.T) {
+		for _, body := range []string{
+			`{"votingMode": "ranked"}`,
+			`{"votingMode": "budget"}`,
+			`{"votingMode": "budget", "voteBudget": 101}`,
+			`{"votingMode": "updown", "voteBudget": 3}`,
+		} {
+			storer.Clear()
+			w := httptest.NewRecorder()
+			r := httptest.NewRequest(http.MethodPost, "/api/session", strings.NewReader(body))
+
+			api.CreateSessionHandler(w, r)
+
+			if w.Code != http.StatusBadRequest {
+				t.Errorf("%s: expected status %d, got %d", body, http.StatusBadRequest, w.Code)
+			}
+		}
+	})
 }
 
 func TestServeWS(t *testing.T) {
@@ -252,6 +297,90 @@ func TestServeWS(t *testing.T) {
 			t.Errorf("Expected status %d, got %d", http.StatusBadRequest, w.Code)
 		}
 	})
+
+	t.Run("Snapshot", func(t *testing.T) {
+		mux := http.NewServeMux()
+		mux.HandleFunc("GET /api/session/{session_id}/ws", api.ServeWS)
+		server := httptest.NewServer(mux)
+		defer server.Close()
+
+		u := "ws" + strings.TrimPrefix(server.URL, "http") + "/api/session/" + sessionID + "/ws"
+		header := http.Header{"Cookie": {userSessionIDCookie + "=u3"}}
+		conn, _, err := websocket.DefaultDialer.Dial(u, header)
+		if err != nil {
+			t.Fatalf("dial failed: %v", err)
+		}
+		defer conn.Close()
+
+		conn.SetReadDeadline(time.Now().Add(time.Second))
+		var event struct {
+			Seq     uint64      `json:"seq"`
+			Type    string      `json:"type"`
+			Payload sessionView `json:"payload"`
+		}
+		if err := conn.ReadJSON(&event); err != nil {
+			t.Fatalf("read failed: %v", err)
+		}
+		if event.Type != "SNAPSHOT" || event.Seq == 0 {
+			t.Fatalf("expected a numbered SNAPSHOT first, got %q (seq %d)", event.Type, event.Seq)
+		}
+		questions := event.Payload.Questions
+		if len(questions) != 2 || questions[0].Votes < questions[1].Votes {
+			t.Fatalf("expected the 2 questions ordered by votes, got %+v", questions)
+		}
+		if questions[0].HasVoted || !questions[1].HasVoted {
+			t.Error("expected hasVoted to reflect the connection's cookie")
+		}
+		if event.Payload.Participants != 1 {
+			t.Errorf("expected 1 participant, got %d", event.Payload.Participants)
+		}
+	})
+}
+
+func TestServeEvents(t *testing.T) {
+	api, storer := setupTestAPI()
+	sessionID := "sse-session"
+	storer.PreloadSession(createMockSession(sessionID, "admin-1", true))
+
+	t.Run("SessionNotFound", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest(http.MethodGet, "/api/session/non-existent/events", nil)
+		r.SetPathValue("session_id", "non-existent")
+		api.ServeEvents(w, r)
+		if w.Code != http.StatusNotFound {
+			t.Errorf("Expected status %d, got %d", http.StatusNotFound, w.Code)
+		}
+	})
+
+	t.Run("StreamsSnapshot", func(t *testing.T) {
+		mux := http.NewServeMux()
+		mux.HandleFunc("GET /api/session/{session_id}/events", api.ServeEvents)
+		server := httptest.NewServer(mux)
+		defer server.Close()
+
+		resp, err := http.Get(server.URL + "/api/session/" + sessionID + "/events")
+		if err != nil {
+			t.Fatalf("request failed: %v", err)
+		}
+		defer resp.Body.Close()
+		if ct := resp.Header.Get("Content-Type"); ct != "text/event-stream" {
+			t.Fatalf("Expected text/event-stream, got %q", ct)
+		}
+
+		stream := bufio.NewReader(resp.Body)
+		for {
+			line, err := stream.ReadString('\n')
+			if err != nil {
+				t.Fatalf("read failed: %v", err)
+			}
+			if data, ok := strings.CutPrefix(line, "data: "); ok {
+				if !strings.Contains(data, `"type":"SNAPSHOT"`) || !strings.Contains(data, `"sessionId":"`+sessionID+`"`) {
+					t.Errorf("Expected a SNAPSHOT of the session first, got %s", data)
+				}
+				break
+			}
+		}
+	})
 }
 
 func TestGetSessionHandler(t *testing.T) {
@@ -279,6 +408,85 @@ func TestGetSessionHandler(t *testing.T) {
 		}
 	})
 
+	t.Run("Participants", func(t *testing.T) {
+		client := &ws.Client{SessionID: sessionID, Send: make(chan []byte, 4)}
+		api.Hub.Register(client)
+		defer api.Hub.Unregister(client)
+
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest(http.MethodGet, "/api/session/"+sessionID, nil)
+		r.SetPathValue("session_id", sessionID)
+		api.GetSessionHandler(w, r)
+
+		var resp struct {
+			Participants int `json:"participants"`
+		}
+		if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+			t.Fatalf("Failed to decode response: %v", err)
+		}
+		if resp.Participants != 1 {
+			t.Errorf("Expected 1 participant, got %d", resp.Participants)
+		}
+	})
+
+	t.Run("HidesVotersAndReportsHasVoted", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest(http.MethodGet, "/api/session/"+sessionID, nil)
+		r.SetPathValue("session_id", sessionID)
+		r.AddCookie(&http.Cookie{Name: "userSessionId", Value: "u3"})
+		api.GetSessionHandler(w, r)
+
+		if strings.Contains(w.Body.String(), "voters") || strings.Contains(w.Body.String(), `"u1"`) {
+			t.Errorf("Response leaks voter IDs: %s", w.Body.String())
+		}
+		var resp struct {
+			Questions []struct {
+				Votes    int  `json:"votes"`
+				HasVoted bool `json:"hasVoted"`
+			} `json:"questions"`
+		}
+		if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+			t.Fatalf("Failed to decode response: %v", err)
+		}
+		// u3 voted only for the 5-vote question, which sorts second.
+		if len(resp.Questions) != 2 || resp.Questions[0].HasVoted || !resp.Questions[1].HasVoted {
+			t.Errorf("Unexpected hasVoted flags: %+v", resp.Questions)
+		}
+	})
+
+	t.Run("ETag", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest(http.MethodGet, "/api/session/"+sessionID, nil)
+		r.SetPathValue("session_id", sessionID)
+		api.GetSessionHandler(w, r)
+
+		etag := w.Header().Get("ETag")
+		if etag != `"0"` {
+			t.Fatalf("Expected ETag %q, got %q", `"0"`, etag)
+		}
+
+		w = httptest.NewRecorder()
+		r = httptest.NewRequest(http.MethodGet, "/api/session/"+sessionID, nil)
+		r.SetPathValue("session_id", sessionID)
+		r.Header.Set("If-None-Match", etag)
+		api.GetSessionHandler(w, r)
+		if w.Code != http.StatusNotModified {
+			t.Fatalf("Expected status %d, got %d", http.StatusNotModified, w.Code)
+		}
+		if w.Body.Len() != 0 {
+			t.Errorf("Expected empty body for 304, got %q", w.Body.String())
+		}
+
+		w = httptest.NewRecorder()
+		r = httptest.NewRequest(http.MethodGet, "/api/session/"+sessionID, nil)
+		r.SetPathValue("session_id", sessionID)
+		r.Header.Set("If-None-Match", `"41"`)
+		api.GetSessionHandler(w, r)
+		if w.Code != http.StatusOK {
+			t.Fatalf("Expected status %d for stale ETag, got %d", http.StatusOK, w.Code)
+		}
+	})
+
 	t.Run("CreatesNewSessionIfNotFound", func(t *testing.T) {
 		storer.Clear() // Make sure no sessions exist
 		newSessionID := "a-new-session"
@@ -441,6 +649,40 @@ func TestVoteQuestionHandler(t *testing.T) {
 		}
 	})
 
+	t.Run("BroadcastsCountOnly", func(t *testing.T) {
+		storer.Clear()
+		storer.PreloadSession(createMockSession(sessionID, adminID, true))
+		client := &ws.Client{SessionID: sessionID, Send: make(chan []byte, 4)}
+		api.Hub.Register(client)
+		defer api.Hub.Unregister(client)
+
+		path := fmt.Sprintf("/api/session/%s/questions/%s/vote", sessionID, questionID)
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest(http.MethodPut, path, nil)
+		r.SetPathValue("session_id", sessionID)
+		r.SetPathValue("question_id", questionID)
+		r.AddCookie(voterCookie)
+		api.VoteQuestionHandler(w, r)
+		if w.Code != http.StatusOK {
+			t.Fatalf("Expected status %d, got %d", http.StatusOK, w.Code)
+		}
+		if strings.Contains(w.Body.String(), "new-voter") || !strings.Contains(w.Body.String(), `"hasVoted":true`) {
+			t.Errorf("Unexpected vote response %s", w.Body.String())
+		}
+
+		msg := <-client.Send
+		var event struct {
+			Type    string                 `json:"type"`
+			Payload map[string]interface{} `json:"payload"`
+		}
+		if err := json.Unmarshal(msg, &event); err != nil {
+			t.Fatalf("invalid event %q: %v", msg, err)
+		}
+		if event.Type != "VOTE_UPDATED" || len(event.Payload) != 2 || event.Payload["votes"] != float64(11) {
+			t.Errorf("Expected VOTE_UPDATED with id and votes only, got %s", msg)
+		}
+	})
+
 	t.Run("AlreadyVoted", func(t *testing.T) {
 		storer.Clear()
 		storer.PreloadSession(createMockSession(sessionID, adminID, true))
@@ -457,97 +699,1126 @@ func TestVoteQuestionHandler(t *testing.T) {
 	})
 }
 
-func TestDeleteQuestionHandler(t *testing.T) {
-	api, storer := setupTestAPI()
-	sessionID := "delete-q-session"
-	adminToken := "secret-admin-token"
+func TestVoteQuestionHandler_AcrossReplicas(t *testing.T) {
+	server := miniredis.RunT(t)
+	storer := testutil.NewMockStorer()
+	newReplica := func() *API {
+		broker, err := ws.NewRedisBroker(context.Background(), "redis://"+server.Addr())
+		if err != nil {
+			t.Fatalf("NewRedisBroker failed: %v", err)
+		}
+		t.Cleanup(func() { broker.Close() })
+		hub, err := ws.NewHubWithBroker(false, broker)
+		if err != nil {
+			t.Fatalf("NewHubWithBroker failed: %v", err)
+		}
+		return New(storer, false, hub)
+	}
+	replicaA, replicaB := newReplica(), newReplica()
+
+	sessionID := "replicated-session"
 	questionID := "00000000-0000-0000-0000-000000000011"
+	storer.PreloadSession(createMockSession(sessionID, "admin-1", true))
+	client := &ws.Client{SessionID: sessionID, Send: make(chan []byte, 4)}
+	replicaB.Hub.Register(client)
+	defer replicaB.Hub.Unregister(client)
 
-	t.Run("Success_Admin", func(t *testing.T) {
-		storer.Clear()
-		storer.PreloadSession(createMockSession(sessionID, adminToken, true))
-		path := fmt.Sprintf("/api/session/%s/questions/%s", sessionID, questionID)
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodPut, fmt.Sprintf("/api/session/%s/questions/%s/vote", sessionID, questionID), nil)
+	r.SetPathValue("session_id", sessionID)
+	r.SetPathValue("question_id", questionID)
+	r.AddCookie(&http.Cookie{Name: userSessionIDCookie, Value: "new-voter"})
+	replicaA.VoteQuestionHandler(w, r)
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status %d, got %d", http.StatusOK, w.Code)
+	}
+
+	select {
+	case msg := <-client.Send:
+		if !strings.Contains(string(msg), `"VOTE_UPDATED"`) || !strings.Contains(string(msg), `"votes":11`) {
+			t.Errorf("Expected VOTE_UPDATED with 11 votes on replica B, got %s", msg)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Expected the vote on replica A to reach replica B")
+	}
+}
+
+func TestVoteQuestionHandler_VotingModes(t *testing.T) {
+	api, storer := setupTestAPI()
+	sessionID := "modes-session"
+	questionIDs := []string{"00000000-0000-0000-0000-000000000011", "00000000-0000-0000-0000-000000000012"}
+
+	vote := func(questionID, userID, body string) *httptest.ResponseRecorder {
+		path := fmt.Sprintf("/api/session/%s/questions/%s/vote", sessionID, questionID)
 		w := httptest.NewRecorder()
-		r := httptest.NewRequest(http.MethodDelete, path, nil)
+		r := httptest.NewRequest(http.MethodPut, path, strings.NewReader(body))
 		r.SetPathValue("session_id", sessionID)
 		r.SetPathValue("question_id", questionID)
-		r.Header.Set("Authorization", "Bearer "+adminToken)
-		api.DeleteQuestionHandler(w, r)
-		if w.Code != http.StatusNoContent {
-			t.Errorf("Expected status %d, got %d", http.StatusNoContent, w.Code)
+		r.AddCookie(&http.Cookie{Name: "userSessionId", Value: userID})
+		api.VoteQuestionHandler(w, r)
+		return w
+	}
+
+	t.Run("Downvote", func(t *testing.T) {
+		storer.Clear()
+		session := createMockSession(sessionID, "admin", true)
+		session.VotingMode = models.VotingModeUpDown
+		storer.PreloadSession(session)
+
+		w := vote(questionIDs[0], "critic", `{"direction": "down"}`)
+		if w.Code != http.StatusOK {
+			t.Fatalf("Expected status %d, got %d", http.StatusOK, w.Code)
 		}
-		session, _ := storer.LoadSessionData(context.Background(), sessionID)
-		if len(session.Questions) != 1 {
-			t.Errorf("Expected 1 question remaining, got %d", len(session.Questions))
+		if !strings.Contains(w.Body.String(), `"myVote":-1`) {
+			t.Errorf("Unexpected vote response %s", w.Body.String())
+		}
+		session, _ = storer.LoadSessionData(context.Background(), sessionID)
+		if session.Questions[0].Votes != 9 {
+			t.Errorf("Expected net score 9, got %d", session.Questions[0].Votes)
+		}
+		if w := vote(questionIDs[0], "critic", ""); w.Code != http.StatusForbidden {
+			t.Errorf("Expected status %d for a second vote, got %d", http.StatusForbidden, w.Code)
 		}
 	})
 
-	t.Run("Unauthorized_User", func(t *testing.T) {
+	t.Run("DownvoteNotEnabled", func(t *testing.T) {
 		storer.Clear()
-		storer.PreloadSession(createMockSession(sessionID, adminToken, true))
-		path := fmt.Sprintf("/api/session/%s/questions/%s", sessionID, questionID)
-		w := httptest.NewRecorder()
-		r := httptest.NewRequest(http.MethodDelete, path, nil)
-		r.SetPathValue("session_id", sessionID)
-		r.SetPathValue("question_id", questionID)
-		r.Header.Set("Authorization", "Bearer invalid-token")
-		api.DeleteQuestionHandler(w, r)
-		if w.Code != http.StatusForbidden {
-			t.Errorf("Expected status %d, got %d", http.StatusForbidden, w.Code)
+		storer.PreloadSession(createMockSession(sessionID, "admin", true))
+		if w := vote(questionIDs[0], "critic", `{"direction": "down"}`); w.Code != http.StatusBadRequest {
+			t.Errorf("Expected status %d, got %d", http.StatusBadRequest, w.Code)
+		}
+		if w := vote(questionIDs[0], "critic", `{"direction": "sideways"}`); w.Code != http.StatusBadRequest {
+			t.Errorf("Expected status %d, got %d", http.StatusBadRequest, w.Code)
+		}
+	})
+
+	t.Run("Budget", func(t *testing.T) {
+		storer.Clear()
+		session := createMockSession(sessionID, "admin", true)
+		session.VotingMode = models.VotingModeBudget
+		session.VoteBudget = 1
+		storer.PreloadSession(session)
+
+		w := vote(questionIDs[0], "voter", "")
+		if w.Code != http.StatusOK {
+			t.Fatalf("Expected status %d, got %d", http.StatusOK, w.Code)
+		}
+		if !strings.Contains(w.Body.String(), `"remainingVotes":0`) {
+			t.Errorf("Expected no votes left, got %s", w.Body.String())
+		}
+		if w := vote(questionIDs[1], "voter", ""); w.Code != http.StatusForbidden {
+			t.Errorf("Expected status %d once the budget is spent, got %d", http.StatusForbidden, w.Code)
+		}
+		session, _ = storer.LoadSessionData(context.Background(), sessionID)
+		if session.Questions[1].Votes != 5 {
+			t.Errorf("Expected vote count to remain 5, got %d", session.Questions[1].Votes)
 		}
 	})
 }
 
-func TestEndSessionHandler(t *testing.T) {
+func TestVoteQuestionHandler_ConcurrentVotes(t *testing.T) {
 	api, storer := setupTestAPI()
-	sessionID := "end-this-session"
-	adminToken := "secret-admin-token"
+	sessionID := "concurrent-vote-session"
+	questionID := "00000000-0000-0000-0000-000000000011"
+	storer.PreloadSession(createMockSession(sessionID, "admin", true))
 
-	t.Run("Success_Admin", func(t *testing.T) {
-		storer.Clear()
-		storer.PreloadSession(createMockSession(sessionID, adminToken, true))
+	const voters = 50
+	path := fmt.Sprintf("/api/session/%s/questions/%s/vote", sessionID, questionID)
+	var wg sync.WaitGroup
+	for i := 0; i < voters; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			w := httptest.NewRecorder()
+			r := httptest.NewRequest(http.MethodPut, path, nil)
+			r.SetPathValue("session_id", sessionID)
+			r.SetPathValue("question_id", questionID)
+			r.AddCookie(&http.Cookie{Name: "userSessionId", Value: fmt.Sprintf("voter-%d", i)})
+			api.VoteQuestionHandler(w, r)
+			if w.Code != http.StatusOK {
+				t.Errorf("voter %d: expected status %d, got %d", i, http.StatusOK, w.Code)
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	session, _ := storer.LoadSessionData(context.Background(), sessionID)
+	if session.Questions[0].Votes != 10+voters {
+		t.Errorf("Expected vote count to be %d, got %d", 10+voters, session.Questions[0].Votes)
+	}
+	if len(session.Questions[0].Voters) != 2+voters {
+		t.Errorf("Expected %d voters, got %d", 2+voters, len(session.Questions[0].Voters))
+	}
+}
+
+func TestUnvoteQuestionHandler(t *testing.T) {
+	api, storer := setupTestAPI()
+	sessionID := "unvote-session"
+	questionID := "00000000-0000-0000-0000-000000000011"
+	path := fmt.Sprintf("/api/session/%s/questions/%s/vote", sessionID, questionID)
+
+	unvote := func(userID string) *httptest.ResponseRecorder {
 		w := httptest.NewRecorder()
-		r := httptest.NewRequest(http.MethodDelete, "/api/session/"+sessionID, nil)
+		r := httptest.NewRequest(http.MethodDelete, path, nil)
 		r.SetPathValue("session_id", sessionID)
-		r.Header.Set("Authorization", "Bearer "+adminToken)
-		api.EndSessionHandler(w, r)
-		if w.Code != http.StatusNoContent {
-			t.Errorf("Expected status %d, got %d", http.StatusNoContent, w.Code)
+		r.SetPathValue("question_id", questionID)
+		r.AddCookie(&http.Cookie{Name: "userSessionId", Value: userID})
+		api.UnvoteQuestionHandler(w, r)
+		return w
+	}
+
+	t.Run("Success", func(t *testing.T) {
+		storer.Clear()
+		storer.PreloadSession(createMockSession(sessionID, "admin", true))
+		client := &ws.Client{SessionID: sessionID, Send: make(chan []byte, 4)}
+		api.Hub.Register(client)
+		defer api.Hub.Unregister(client)
+
+		w := unvote("u1") // u1 voted in mock
+		if w.Code != http.StatusOK {
+			t.Fatalf("Expected status %d, got %d", http.StatusOK, w.Code)
 		}
-		if storer.HasSession(sessionID) {
-			t.Error("Session was not deleted from storer")
+		if !strings.Contains(w.Body.String(), `"hasVoted":false`) {
+			t.Errorf("Unexpected unvote response %s", w.Body.String())
+		}
+		session, _ := storer.LoadSessionData(context.Background(), sessionID)
+		if session.Questions[0].Votes != 9 || len(session.Questions[0].Voters) != 1 {
+			t.Errorf("Expected 9 votes from 1 voter, got %d from %v", session.Questions[0].Votes, session.Questions[0].Voters)
+		}
+		if event := nextEvent(t, client); event != "VOTE_UPDATED" {
+			t.Errorf("Expected VOTE_UPDATED, got %q", event)
 		}
 	})
 
-	t.Run("Unauthorized_User", func(t *testing.T) {
+	t.Run("NotVoted", func(t *testing.T) {
 		storer.Clear()
-		storer.PreloadSession(createMockSession(sessionID, adminToken, true))
-		w := httptest.NewRecorder()
-		r := httptest.NewRequest(http.MethodDelete, "/api/session/"+sessionID, nil)
-		r.SetPathValue("session_id", sessionID)
-		r.Header.Set("Authorization", "Bearer invalid-token")
-		api.EndSessionHandler(w, r)
-		if w.Code != http.StatusForbidden {
+		storer.PreloadSession(createMockSession(sessionID, "admin", true))
+		if w := unvote("u3"); w.Code != http.StatusForbidden { // u3 voted on the other question
 			t.Errorf("Expected status %d, got %d", http.StatusForbidden, w.Code)
 		}
-		if !storer.HasSession(sessionID) {
-			t.Error("Session was incorrectly deleted by non-admin")
+		session, _ := storer.LoadSessionData(context.Background(), sessionID)
+		if session.Questions[0].Votes != 10 {
+			t.Errorf("Expected vote count to remain 10, got %d", session.Questions[0].Votes)
+		}
+	})
+
+	t.Run("SessionClosed", func(t *testing.T) {
+		storer.Clear()
+		storer.PreloadSession(createMockSession(sessionID, "admin", false))
+		if w := unvote("u1"); w.Code != http.StatusForbidden {
+			t.Errorf("Expected status %d, got %d", http.StatusForbidden, w.Code)
+		}
+	})
+
+	t.Run("ConcurrentVoteAndUnvote", func(t *testing.T) {
+		storer.Clear()
+		storer.PreloadSession(createMockSession(sessionID, "admin", true))
+		const voters = 25
+		var wg sync.WaitGroup
+		for i := 0; i < voters; i++ {
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+				userID := fmt.Sprintf("voter-%d", i)
+				w := httptest.NewRecorder()
+				r := httptest.NewRequest(http.MethodPut, path, nil)
+				r.SetPathValue("session_id", sessionID)
+				r.SetPathValue("question_id", questionID)
+				r.AddCookie(&http.Cookie{Name: "userSessionId", Value: userID})
+				api.VoteQuestionHandler(w, r)
+				if i%2 == 0 {
+					unvote(userID)
+				}
+			}(i)
+		}
+		wg.Wait()
+
+		session, _ := storer.LoadSessionData(context.Background(), sessionID)
+		want := 10 + voters/2
+		if session.Questions[0].Votes != want || len(session.Questions[0].Voters) != 2+voters/2 {
+			t.Errorf("Expected %d votes from %d voters, got %d from %d",
+				want, 2+voters/2, session.Questions[0].Votes, len(session.Questions[0].Voters))
 		}
 	})
 }
 
-func TestSubmitQuestionHandler_BannedIP(t *testing.T) {
+func TestUpdateQuestionHandler(t *testing.T) {
 	api, storer := setupTestAPI()
-	sessionID := "ban-submit-session"
-	bannedIP := "10.0.0.1"
-	session := createMockSession(sessionID, "admin-token", true)
-	session.BannedIPs = []string{bannedIP}
-	storer.PreloadSession(session)
+	sessionID := "status-session"
+	adminToken := "secret-admin-token"
+	questionIDs := []string{"00000000-0000-0000-0000-000000000011", "00000000-0000-0000-0000-000000000012"}
 
-	t.Run("BannedIPRejected", func(t *testing.T) {
-		body := `{"text": "spam question"}`
+	update := func(questionID, token, body string) *httptest.ResponseRecorder {
+		path := fmt.Sprintf("/api/session/%s/questions/%s", sessionID, questionID)
 		w := httptest.NewRecorder()
-		r := httptest.NewRequest(http.MethodPost, "/api/session/"+sessionID+"/questions", strings.NewReader(body))
+		r := httptest.NewRequest(http.MethodPatch, path, strings.NewReader(body))
+		r.SetPathValue("session_id", sessionID)
+		r.SetPathValue("question_id", questionID)
+		r.Header.Set("Authorization", "Bearer "+token)
+		api.UpdateQuestionHandler(w, r)
+		return w
+	}
+	getQuestions := func(token string) []models.Question {
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest(http.MethodGet, "/api/session/"+sessionID, nil)
+		r.SetPathValue("session_id", sessionID)
+		if token != "" {
+			r.Header.Set("Authorization", "Bearer "+token)
+		}
+		api.GetSessionHandler(w, r)
+		var resp struct {
+			Questions []models.Question `json:"questions"`
+		}
+		if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+			t.Fatalf("Failed to decode response: %v", err)
+		}
+		return resp.Questions
+	}
+
+	t.Run("Success_Admin", func(t *testing.T) {
+		storer.Clear()
+		storer.PreloadSession(createMockSession(sessionID, adminToken, true))
+		client := &ws.Client{SessionID: sessionID, Send: make(chan []byte, 4)}
+		api.Hub.Register(client)
+		defer api.Hub.Unregister(client)
+
+		w := update(questionIDs[0], adminToken, `{"status": "answered"}`)
+		if w.Code != http.StatusOK {
+			t.Fatalf("Expected status %d, got %d", http.StatusOK, w.Code)
+		}
+		if !strings.Contains(w.Body.String(), `"status":"answered"`) {
+			t.Errorf("Unexpected response %s", w.Body.String())
+		}
+		if event := nextEvent(t, client); event != "QUESTION_UPDATED" {
+			t.Errorf("Expected QUESTION_UPDATED, got %q", event)
+		}
+		session, _ := storer.LoadSessionData(context.Background(), sessionID)
+		if session.Questions[0].Status != models.QuestionAnswered {
+			t.Errorf("Expected status answered, got %q", session.Questions[0].Status)
+		}
+	})
+
+	t.Run("Ordering", func(t *testing.T) {
+		storer.Clear()
+		session := createMockSession(sessionID, adminToken, true)
+		session.Questions = append(session.Questions,
+			models.Question{ID: "q-pinned", Votes: 1, Status: models.QuestionPinned},
+			models.Question{ID: "q-answered", Votes: 50, Status: models.QuestionAnswered},
+			models.Question{ID: "q-hidden", Votes: 99, Status: models.QuestionHidden},
+		)
+		storer.PreloadSession(session)
+
+		var got []string
+		for _, q := range getQuestions("") {
+			got = append(got, q.ID)
+		}
+		want := []string{"q-pinned", questionIDs[0], questionIDs[1], "q-answered"}
+		if !slices.Equal(got, want) {
+			t.Errorf("Expected order %v, got %v", want, got)
+		}
+		if questions := getQuestions(adminToken); len(questions) != 5 || questions[4].ID != "q-hidden" {
+			t.Errorf("Expected the admin to see the hidden question last, got %+v", questions)
+		}
+	})
+
+	t.Run("HidingSendsParticipantsOnlyTheID", func(t *testing.T) {
+		storer.Clear()
+		storer.PreloadSession(createMockSession(sessionID, adminToken, true))
+		participant := &ws.Client{SessionID: sessionID, Send: make(chan []byte, 4)}
+		admin := &ws.Client{SessionID: sessionID, Role: ws.RoleAdmin, Send: make(chan []byte, 4)}
+		api.Hub.Register(participant)
+		api.Hub.Register(admin)
+		defer api.Hub.Unregister(participant)
+		defer api.Hub.Unregister(admin)
+
+		if w := update(questionIDs[0], adminToken, `{"status": "hidden"}`); w.Code != http.StatusOK {
+			t.Fatalf("Expected status %d, got %d", http.StatusOK, w.Code)
+		}
+		if event := nextEvent(t, admin); event != "QUESTION_UPDATED" {
+			t.Errorf("Expected QUESTION_UPDATED for the admin, got %q", event)
+		}
+		var msg []byte
+		select {
+		case msg = <-participant.Send:
+		default:
+			t.Fatal("Expected an event for the participant")
+		}
+		var event struct {
+			Type    string            `json:"type"`
+			Payload map[string]string `json:"payload"`
+		}
+		if err := json.Unmarshal(msg, &event); err != nil {
+			t.Fatalf("invalid event %q: %v", msg, err)
+		}
+		if event.Type != "QUESTION_HIDDEN" || len(event.Payload) != 1 || event.Payload["id"] != questionIDs[0] {
+			t.Errorf("Expected QUESTION_HIDDEN with only the question ID, got %s", msg)
+		}
+
+		if w := update(questionIDs[0], adminToken, `{"status": "open"}`); w.Code != http.StatusOK {
+			t.Fatalf("Expected status %d, got %d", http.StatusOK, w.Code)
+		}
+		if event := nextEvent(t, participant); event != "QUESTION_UPDATED" {
+			t.Errorf("Expected the unhidden question as QUESTION_UPDATED, got %q", event)
+		}
+	})
+
+	t.Run("HiddenQuestionCannotBeVotedOn", func(t *testing.T) {
+		storer.Clear()
+		storer.PreloadSession(createMockSession(sessionID, adminToken, true))
+		if w := update(questionIDs[1], adminToken, `{"status": "hidden"}`); w.Code != http.StatusOK {
+			t.Fatalf("Expected status %d, got %d", http.StatusOK, w.Code)
+		}
+		path := fmt.Sprintf("/api/session/%s/questions/%s/vote", sessionID, questionIDs[1])
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest(http.MethodPut, path, nil)
+		r.SetPathValue("session_id", sessionID)
+		r.SetPathValue("question_id", questionIDs[1])
+		r.AddCookie(&http.Cookie{Name: "userSessionId", Value: "voter"})
+		api.VoteQuestionHandler(w, r)
+		if w.Code != http.StatusNotFound {
+			t.Errorf("Expected status %d, got %d", http.StatusNotFound, w.Code)
+		}
+	})
+
+	t.Run("InvalidStatus", func(t *testing.T) {
+		storer.Clear()
+		storer.PreloadSession(createMockSession(sessionID, adminToken, true))
+		if w := update(questionIDs[0], adminToken, `{"status": "archived"}`); w.Code != http.StatusBadRequest {
+			t.Errorf("Expected status %d, got %d", http.StatusBadRequest, w.Code)
+		}
+	})
+
+	t.Run("QuestionNotFound", func(t *testing.T) {
+		storer.Clear()
+		storer.PreloadSession(createMockSession(sessionID, adminToken, true))
+		if w := update("00000000-0000-0000-0000-000000000099", adminToken, `{"status": "pinned"}`); w.Code != http.StatusNotFound {
+			t.Errorf("Expected status %d, got %d", http.StatusNotFound, w.Code)
+		}
+	})
+
+	t.Run("Unauthorized_User", func(t *testing.T) {
+		storer.Clear()
+		storer.PreloadSession(createMockSession(sessionID, adminToken, true))
+		if w := update(questionIDs[0], "invalid-token", `{"status": "pinned"}`); w.Code != http.StatusForbidden {
+			t.Errorf("Expected status %d, got %d", http.StatusForbidden, w.Code)
+		}
+	})
+}
+
+func TestModerationQueue(t *testing.T) {
+	api, storer := setupTestAPI()
+	sessionID := "moderated-session"
+	adminToken := "secret-admin-token"
+
+	setup := func(t *testing.T) (admin, participant *ws.Client) {
+		t.Helper()
+		storer.Clear()
+		session := createMockSession(sessionID, adminToken, true)
+		session.Moderated = true
+		storer.PreloadSession(session)
+		admin = &ws.Client{SessionID: sessionID, Role: ws.RoleAdmin, Send: make(chan []byte, 4)}
+		participant = &ws.Client{SessionID: sessionID, Send: make(chan []byte, 4)}
+		api.Hub.Register(admin)
+		api.Hub.Register(participant)
+		t.Cleanup(func() {
+			api.Hub.Unregister(admin)
+			api.Hub.Unregister(participant)
+		})
+		return admin, participant
+	}
+	submit := func(t *testing.T) string {
+		t.Helper()
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest(http.MethodPost, "/api/session/"+sessionID+"/questions", strings.NewReader(`{"text": "Layoffs?"}`))
+		r.SetPathValue("session_id", sessionID)
+		api.SubmitQuestionHandler(w, r)
+		if w.Code != http.StatusAccepted {
+			t.Fatalf("Expected status %d, got %d", http.StatusAccepted, w.Code)
+		}
+		var q models.Question
+		json.Unmarshal(w.Body.Bytes(), &q)
+		if q.Status != models.QuestionPending {
+			t.Errorf("Expected a pending question, got %q", q.Status)
+		}
+		return q.ID
+	}
+	moderate := func(handler http.HandlerFunc, questionID, token string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest(http.MethodPost, "/api/session/"+sessionID+"/questions/"+questionID, nil)
+		r.SetPathValue("session_id", sessionID)
+		r.SetPathValue("question_id", questionID)
+		r.Header.Set("Authorization", "Bearer "+token)
+		handler(w, r)
+		return w
+	}
+
+	t.Run("SubmitNotifiesAdminsOnly", func(t *testing.T) {
+		admin, participant := setup(t)
+		submit(t)
+		select {
+		case msg := <-admin.Send:
+			if !strings.Contains(string(msg), `"QUESTION_PENDING"`) || !strings.Contains(string(msg), `"hasSubmitter":true`) {
+				t.Errorf("Expected QUESTION_PENDING with a submitter for the admin, got %s", msg)
+			}
+		default:
+			t.Error("Expected QUESTION_PENDING for the admin")
+		}
+		if event := nextEvent(t, participant); event != "" {
+			t.Errorf("Expected no event for the participant, got %q", event)
+		}
+
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest(http.MethodGet, "/api/session/"+sessionID, nil)
+		r.SetPathValue("session_id", sessionID)
+		api.GetSessionHandler(w, r)
+		if strings.Contains(w.Body.String(), "Layoffs?") {
+			t.Errorf("Pending question visible to participants: %s", w.Body.String())
+		}
+	})
+
+	t.Run("Approve", func(t *testing.T) {
+		admin, participant := setup(t)
+		questionID := submit(t)
+		nextEvent(t, admin)
+
+		if w := moderate(api.ApproveQuestionHandler, questionID, "invalid-token"); w.Code != http.StatusForbidden {
+			t.Errorf("Expected status %d, got %d", http.StatusForbidden, w.Code)
+		}
+		w := moderate(api.ApproveQuestionHandler, questionID, adminToken)
+		if w.Code != http.StatusOK {
+			t.Fatalf("Expected status %d, got %d", http.StatusOK, w.Code)
+		}
+		if event := nextEvent(t, participant); event != "QUESTION_ADDED" {
+			t.Errorf("Expected QUESTION_ADDED, got %q", event)
+		}
+		session, _ := storer.LoadSessionData(context.Background(), sessionID)
+		if q := session.Questions[2]; q.Status != models.QuestionOpen {
+			t.Errorf("Expected the approved question to be open, got %q", q.Status)
+		}
+		if w := moderate(api.ApproveQuestionHandler, questionID, adminToken); w.Code != http.StatusConflict {
+			t.Errorf("Expected status %d for a second approval, got %d", http.StatusConflict, w.Code)
+		}
+	})
+
+	t.Run("Reject", func(t *testing.T) {
+		admin, participant := setup(t)
+		questionID := submit(t)
+		nextEvent(t, admin)
+
+		w := moderate(api.RejectQuestionHandler, questionID, adminToken)
+		if w.Code != http.StatusNoContent {
+			t.Fatalf("Expected status %d, got %d", http.StatusNoContent, w.Code)
+		}
+		if event := nextEvent(t, admin); event != "QUESTION_REJECTED" {
+			t.Errorf("Expected QUESTION_REJECTED for the admin, got %q", event)
+		}
+		if event := nextEvent(t, participant); event != "" {
+			t.Errorf("Expected no event for the participant, got %q", event)
+		}
+		session, _ := storer.LoadSessionData(context.Background(), sessionID)
+		if len(session.Questions) != 2 {
+			t.Errorf("Expected the rejected question to be deleted, got %d questions", len(session.Questions))
+		}
+	})
+
+	t.Run("PatchCannotBypassQueue", func(t *testing.T) {
+		admin, _ := setup(t)
+		questionID := submit(t)
+		nextEvent(t, admin)
+
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest(http.MethodPatch, "/api/session/"+sessionID+"/questions/"+questionID, strings.NewReader(`{"status": "open"}`))
+		r.SetPathValue("session_id", sessionID)
+		r.SetPathValue("question_id", questionID)
+		r.Header.Set("Authorization", "Bearer "+adminToken)
+		api.UpdateQuestionHandler(w, r)
+		if w.Code != http.StatusConflict {
+			t.Errorf("Expected status %d, got %d", http.StatusConflict, w.Code)
+		}
+	})
+}
+
+func TestDeleteQuestionHandler(t *testing.T) {
+	api, storer := setupTestAPI()
+	sessionID := "delete-q-session"
+	adminToken := "secret-admin-token"
+	questionID := "00000000-0000-0000-0000-000000000011"
+
+	t.Run("Success_Admin", func(t *testing.T) {
+		storer.Clear()
+		storer.PreloadSession(createMockSession(sessionID, adminToken, true))
+		path := fmt.Sprintf("/api/session/%s/questions/%s", sessionID, questionID)
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest(http.MethodDelete, path, nil)
+		r.SetPathValue("session_id", sessionID)
+		r.SetPathValue("question_id", questionID)
+		r.Header.Set("Authorization", "Bearer "+adminToken)
+		api.DeleteQuestionHandler(w, r)
+		if w.Code != http.StatusNoContent {
+			t.Errorf("Expected status %d, got %d", http.StatusNoContent, w.Code)
+		}
+		session, _ := storer.LoadSessionData(context.Background(), sessionID)
+		if len(session.Questions) != 1 {
+			t.Errorf("Expected 1 question remaining, got %d", len(session.Questions))
+		}
+	})
+
+	t.Run("Unauthorized_User", func(t *testing.T) {
+		storer.Clear()
+		storer.PreloadSession(createMockSession(sessionID, adminToken, true))
+		path := fmt.Sprintf("/api/session/%s/questions/%s", sessionID, questionID)
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest(http.MethodDelete, path, nil)
+		r.SetPathValue("session_id", sessionID)
+		r.SetPathValue("question_id", questionID)
+		r.Header.Set("Authorization", "Bearer invalid-token")
+		api.DeleteQuestionHandler(w, r)
+		if w.Code != http.StatusForbidden {
+			t.Errorf("Expected status %d, got %d", http.StatusForbidden, w.Code)
+		}
+	})
+}
+
+func TestEndSessionHandler(t *testing.T) {
+	api, storer := setupTestAPI()
+	sessionID := "end-this-session"
+	adminToken := "secret-admin-token"
+
+	t.Run("Success_Admin", func(t *testing.T) {
+		storer.Clear()
+		storer.PreloadSession(createMockSession(sessionID, adminToken, true))
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest(http.MethodDelete, "/api/session/"+sessionID, nil)
+		r.SetPathValue("session_id", sessionID)
+		r.Header.Set("Authorization", "Bearer "+adminToken)
+		api.EndSessionHandler(w, r)
+		if w.Code != http.StatusNoContent {
+			t.Errorf("Expected status %d, got %d", http.StatusNoContent, w.Code)
+		}
+		if storer.HasSession(sessionID) {
+			t.Error("Session was not deleted from storer")
+		}
+	})
+
+	t.Run("Success_ArchivingDisabled", func(t *testing.T) {
+		storer.Clear()
+		storer.PreloadSession(createMockSession(sessionID, adminToken, true))
+		api.ArchiveRetention = 0
+		defer func() { api.ArchiveRetention = 30 * 24 * time.Hour }()
+
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest(http.MethodDelete, "/api/session/"+sessionID, nil)
+		r.SetPathValue("session_id", sessionID)
+		r.Header.Set("Authorization", "Bearer "+adminToken)
+		api.EndSessionHandler(w, r)
+		if w.Code != http.StatusNoContent {
+			t.Errorf("Expected status %d, got %d", http.StatusNoContent, w.Code)
+		}
+		if storer.HasSession(sessionID) || storer.HasArchivedSession(sessionID) {
+			t.Error("Session should be deleted without an archive")
+		}
+	})
+
+	t.Run("Unauthorized_User", func(t *testing.T) {
+		storer.Clear()
+		storer.PreloadSession(createMockSession(sessionID, adminToken, true))
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest(http.MethodDelete, "/api/session/"+sessionID, nil)
+		r.SetPathValue("session_id", sessionID)
+		r.Header.Set("Authorization", "Bearer invalid-token")
+		api.EndSessionHandler(w, r)
+		if w.Code != http.StatusForbidden {
+			t.Errorf("Expected status %d, got %d", http.StatusForbidden, w.Code)
+		}
+		if !storer.HasSession(sessionID) {
+			t.Error("Session was incorrectly deleted by non-admin")
+		}
+	})
+}
+
+func TestArchiveHandler(t *testing.T) {
+	api, storer := setupTestAPI()
+	sessionID := "archived-session"
+	adminToken := "secret-admin-token"
+
+	getArchive := func(token string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest(http.MethodGet, "/api/session/"+sessionID+"/archive", nil)
+		r.SetPathValue("session_id", sessionID)
+		r.Header.Set("Authorization", "Bearer "+token)
+		api.ArchiveHandler(w, r)
+		return w
+	}
+
+	t.Run("NotFound", func(t *testing.T) {
+		storer.Clear()
+		storer.PreloadSession(createMockSession(sessionID, adminToken, true))
+		if w := getArchive(adminToken); w.Code != http.StatusNotFound {
+			t.Errorf("Expected status %d for a live session, got %d", http.StatusNotFound, w.Code)
+		}
+	})
+
+	t.Run("Success_Admin", func(t *testing.T) {
+		storer.Clear()
+		session := createMockSession(sessionID, adminToken, true)
+		session.Questions[0], session.Questions[1] = session.Questions[1], session.Questions[0]
+		storer.PreloadSession(session)
+		retainUntil := time.Now().Add(time.Hour).Truncate(time.Second)
+		if err := storer.ArchiveSession(context.Background(), sessionID, retainUntil); err != nil {
+			t.Fatalf("ArchiveSession failed: %v", err)
+		}
+
+		w := getArchive(adminToken)
+		if w.Code != http.StatusOK {
+			t.Fatalf("Expected status %d, got %d", http.StatusOK, w.Code)
+		}
+		if strings.Contains(w.Body.String(), adminToken) {
+			t.Error("Archive response leaks the admin token")
+		}
+		var resp struct {
+			SessionID   string            `json:"sessionId"`
+			RetainUntil time.Time         `json:"retainUntil"`
+			Questions   []models.Question `json:"questions"`
+		}
+		if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+			t.Fatalf("Failed to decode response: %v", err)
+		}
+		if resp.SessionID != sessionID || !resp.RetainUntil.Equal(retainUntil) {
+			t.Errorf("Unexpected archive %+v", resp)
+		}
+		if len(resp.Questions) != 2 || resp.Questions[0].Votes != 10 {
+			t.Errorf("Expected questions sorted by votes, got %+v", resp.Questions)
+		}
+	})
+
+	t.Run("Unauthorized_User", func(t *testing.T) {
+		if w := getArchive("invalid-token"); w.Code != http.StatusForbidden {
+			t.Errorf("Expected status %d, got %d", http.StatusForbidden, w.Code)
+		}
+	})
+
+	t.Run("EndSessionArchives", func(t *testing.T) {
+		storer.Clear()
+		session := createMockSession(sessionID, adminToken, true)
+		session.Questions[0].SubmitterIP = "203.0.113.7"
+		session.BannedIPs = []string{"203.0.113.8"}
+		storer.PreloadSession(session)
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest(http.MethodDelete, "/api/session/"+sessionID, nil)
+		r.SetPathValue("session_id", sessionID)
+		r.Header.Set("Authorization", "Bearer "+adminToken)
+		api.EndSessionHandler(w, r)
+		if w.Code != http.StatusNoContent {
+			t.Fatalf("Expected status %d, got %d", http.StatusNoContent, w.Code)
+		}
+		if storer.HasSession(sessionID) {
+			t.Error("Ended session is still live")
+		}
+		if w := getArchive(adminToken); w.Code != http.StatusOK {
+			t.Errorf("Expected the ended session to be archived, got status %d", w.Code)
+		}
+		archived, err := storer.LoadArchivedSession(context.Background(), sessionID)
+		if err != nil {
+			t.Fatalf("LoadArchivedSession failed: %v", err)
+		}
+		if archived.Session.BannedIPs != nil || archived.Session.Questions[0].SubmitterIP != "" {
+			t.Errorf("Expected the archive to drop submitter IPs and bans, got %+v", archived.Session)
+		}
+	})
+
+	t.Run("ArchivedIDIsNotReused", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest(http.MethodGet, "/api/session/"+sessionID, nil)
+		r.SetPathValue("session_id", sessionID)
+		api.GetSessionHandler(w, r)
+		if w.Code != http.StatusGone {
+			t.Errorf("Expected status %d for an ended session, got %d", http.StatusGone, w.Code)
+		}
+		if storer.HasSession(sessionID) {
+			t.Error("Expected no live session under the archived ID")
+		}
+
+		w = httptest.NewRecorder()
+		r = httptest.NewRequest(http.MethodPost, "/api/session", strings.NewReader(`{"sessionId": "`+sessionID+`"}`))
+		api.CreateSessionHandler(w, r)
+		var resp struct {
+			SessionID string `json:"sessionId"`
+		}
+		json.NewDecoder(w.Body).Decode(&resp)
+		if w.Code != http.StatusCreated || resp.SessionID == sessionID {
+			t.Errorf("Expected a new session under another ID, got %d %q", w.Code, resp.SessionID)
+		}
+	})
+}
+
+func TestExportSessionHandler(t *testing.T) {
+	api, storer := setupTestAPI()
+	sessionID := "export-session"
+	adminToken := "secret-admin-token"
+
+	exportSession := func(token, format string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest(http.MethodGet, "/api/session/"+sessionID+"/export?format="+format, nil)
+		r.SetPathValue("session_id", sessionID)
+		r.Header.Set("Authorization", "Bearer "+token)
+		api.ExportSessionHandler(w, r)
+		return w
+	}
+
+	t.Run("Success_Formats", func(t *testing.T) {
+		storer.Clear()
+		storer.PreloadSession(createMockSession(sessionID, adminToken, true))
+		for format, contentType := range map[string]string{
+			"":     "application/json",
+			"json": "application/json",
+			"csv":  "text/csv; charset=utf-8",
+			"md":   "text/markdown; charset=utf-8",
+		} {
+			w := exportSession(adminToken, format)
+			if w.Code != http.StatusOK {
+				t.Fatalf("format %q: expected status %d, got %d", format, http.StatusOK, w.Code)
+			}
+			if got := w.Header().Get("Content-Type"); got != contentType {
+				t.Errorf("format %q: expected Content-Type %q, got %q", format, contentType, got)
+			}
+			if !strings.Contains(w.Header().Get("Content-Disposition"), "attachment") {
+				t.Errorf("format %q: expected an attachment, got %q", format, w.Header().Get("Content-Disposition"))
+			}
+			if !strings.Contains(w.Body.String(), "Question One (10 votes)") {
+				t.Errorf("format %q: questions missing from export:\n%s", format, w.Body.String())
+			}
+		}
+	})
+
+	t.Run("UnsupportedFormat", func(t *testing.T) {
+		if w := exportSession(adminToken, "xlsx"); w.Code != http.StatusBadRequest {
+			t.Errorf("Expected status %d, got %d", http.StatusBadRequest, w.Code)
+		}
+	})
+
+	t.Run("Unauthorized_User", func(t *testing.T) {
+		if w := exportSession("invalid-token", "json"); w.Code != http.StatusForbidden {
+			t.Errorf("Expected status %d, got %d", http.StatusForbidden, w.Code)
+		}
+	})
+
+	t.Run("ArchivedSession", func(t *testing.T) {
+		storer.Clear()
+		storer.PreloadSession(createMockSession(sessionID, adminToken, true))
+		if err := storer.ArchiveSession(context.Background(), sessionID, time.Now().Add(time.Hour)); err != nil {
+			t.Fatalf("ArchiveSession failed: %v", err)
+		}
+		if w := exportSession(adminToken, "csv"); w.Code != http.StatusOK {
+			t.Errorf("Expected status %d, got %d", http.StatusOK, w.Code)
+		}
+	})
+
+	t.Run("NotFound", func(t *testing.T) {
+		storer.Clear()
+		if w := exportSession(adminToken, "json"); w.Code != http.StatusNotFound {
+			t.Errorf("Expected status %d, got %d", http.StatusNotFound, w.Code)
+		}
+	})
+}
+
+func TestImportQuestionsHandler(t *testing.T) {
+	api, storer := setupTestAPI()
+	sessionID := "import-session"
+	adminToken := "secret-admin-token"
+
+	importQuestions := func(token, contentType, body string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest(http.MethodPost, "/api/session/"+sessionID+"/questions/import", strings.NewReader(body))
+		r.SetPathValue("session_id", sessionID)
+		r.Header.Set("Authorization", "Bearer "+token)
+		r.Header.Set("Content-Type", contentType)
+		api.ImportQuestionsHandler(w, r)
+		return w
+	}
+	newSession := func() {
+		storer.Clear()
+		session := createMockSession(sessionID, adminToken, true)
+		session.Questions = []models.Question{}
+		storer.PreloadSession(session)
+	}
+
+	t.Run("Success_JSON", func(t *testing.T) {
+		newSession()
+		client := &ws.Client{SessionID: sessionID, Send: make(chan []byte, 4)}
+		api.Hub.Register(client)
+		defer api.Hub.Unregister(client)
+
+		w := importQuestions(adminToken, "application/json", `["First?", {"text": "Second?", "votes": 7}]`)
+		if w.Code != http.StatusCreated {
+			t.Fatalf("Expected status %d, got %d: %s", http.StatusCreated, w.Code, w.Body.String())
+		}
+		got, _ := storer.LoadSessionData(context.Background(), sessionID)
+		if len(got.Questions) != 2 || got.Questions[0].Text != "First?" || got.Questions[1].Votes != 7 {
+			t.Errorf("Unexpected questions %+v", got.Questions)
+		}
+		if got.Version != 1 {
+			t.Errorf("Expected a single storage write, got version %d", got.Version)
+		}
+		if event := nextEvent(t, client); event != "QUESTIONS_IMPORTED" {
+			t.Errorf("Expected QUESTIONS_IMPORTED, got %q", event)
+		}
+		if event := nextEvent(t, client); event != "" {
+			t.Errorf("Expected a single event, got another %q", event)
+		}
+	})
+
+	t.Run("Success_CSV", func(t *testing.T) {
+		newSession()
+		w := importQuestions(adminToken, "text/csv", "text,votes\n\"Why, though?\",3\nWhen?\n")
+		if w.Code != http.StatusCreated {
+			t.Fatalf("Expected status %d, got %d: %s", http.StatusCreated, w.Code, w.Body.String())
+		}
+		got, _ := storer.LoadSessionData(context.Background(), sessionID)
+		if len(got.Questions) != 2 || got.Questions[0].Text != "Why, though?" || got.Questions[0].Votes != 3 || got.Questions[1].Votes != 0 {
+			t.Errorf("Unexpected questions %+v", got.Questions)
+		}
+	})
+
+	t.Run("InvalidEntries", func(t *testing.T) {
+		for name, body := range map[string]string{
+			"not an array": `{"text": "Hi"}`,
+			"empty array":  `[]`,
+			"empty text":   `["Fine", "  "]`,
+			"too long":     `["` + strings.Repeat("a", 501) + `"]`,
+			"negative":     `[{"text": "Hi", "votes": -1}]`,
+		} {
+			newSession()
+			if w := importQuestions(adminToken, "application/json", body); w.Code != http.StatusBadRequest {
+				t.Errorf("%s: expected status %d, got %d", name, http.StatusBadRequest, w.Code)
+			}
+			if got, _ := storer.LoadSessionData(context.Background(), sessionID); len(got.Questions) != 0 {
+				t.Errorf("%s: expected nothing to be imported, got %d questions", name, len(got.Questions))
+			}
+		}
+	})
+
+	t.Run("LimitReached", func(t *testing.T) {
+		newSession()
+		texts := make([]string, maxQuestionsPerSession+1)
+		for i := range texts {
+			texts[i] = fmt.Sprintf("Question %d", i)
+		}
+		body, _ := json.Marshal(texts)
+		if w := importQuestions(adminToken, "application/json", string(body)); w.Code != http.StatusForbidden {
+			t.Errorf("Expected status %d, got %d", http.StatusForbidden, w.Code)
+		}
+		if got, _ := storer.LoadSessionData(context.Background(), sessionID); len(got.Questions) != 0 {
+			t.Errorf("Expected nothing to be imported, got %d questions", len(got.Questions))
+		}
+	})
+
+	t.Run("Unauthorized_User", func(t *testing.T) {
+		newSession()
+		if w := importQuestions("invalid-token", "application/json", `["Hi"]`); w.Code != http.StatusForbidden {
+			t.Errorf("Expected status %d, got %d", http.StatusForbidden, w.Code)
+		}
+		// The body is not looked at before the caller is authorized.
+		if w := importQuestions("invalid-token", "application/json", `not json`); w.Code != http.StatusForbidden {
+			t.Errorf("Expected status %d for an invalid body, got %d", http.StatusForbidden, w.Code)
+		}
+	})
+}
+
+func TestExtendSessionHandler(t *testing.T) {
+	api, storer := setupTestAPI()
+	sessionID := "extend-session"
+	adminToken := "secret-admin-token"
+
+	extend := func(token, body string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest(http.MethodPost, "/api/session/"+sessionID+"/extend", strings.NewReader(body))
+		r.SetPathValue("session_id", sessionID)
+		r.Header.Set("Authorization", "Bearer "+token)
+		api.ExtendSessionHandler(w, r)
+		return w
+	}
+
+	t.Run("Success_DefaultDuration", func(t *testing.T) {
+		storer.Clear()
+		session := createMockSession(sessionID, adminToken, true)
+		session.ExpiresAt = time.Now().Add(time.Hour)
+		storer.PreloadSession(session)
+		expiresAt := session.ExpiresAt
+
+		w := extend(adminToken, "")
+		if w.Code != http.StatusOK {
+			t.Fatalf("Expected status %d, got %d", http.StatusOK, w.Code)
+		}
+		got, _ := storer.LoadSessionData(context.Background(), sessionID)
+		if want := expiresAt.Add(api.SessionTTL); !got.ExpiresAt.Equal(want) {
+			t.Errorf("Expected expiry %v, got %v", want, got.ExpiresAt)
+		}
+	})
+
+	t.Run("Success_ExpiredSessionExtendsFromNow", func(t *testing.T) {
+		storer.Clear()
+		session := createMockSession(sessionID, adminToken, true)
+		session.ExpiresAt = time.Now().Add(-time.Hour)
+		storer.PreloadSession(session)
+
+		w := extend(adminToken, `{"duration": "2h"}`)
+		if w.Code != http.StatusOK {
+			t.Fatalf("Expected status %d, got %d", http.StatusOK, w.Code)
+		}
+		var resp struct {
+			ExpiresAt time.Time `json:"expiresAt"`
+		}
+		json.NewDecoder(w.Body).Decode(&resp)
+		if remaining := time.Until(resp.ExpiresAt); remaining < 119*time.Minute || remaining > 2*time.Hour {
+			t.Errorf("Expected expiry about 2h from now, got %v", resp.ExpiresAt)
+		}
+	})
+
+	t.Run("InvalidDuration", func(t *testing.T) {
+		storer.Clear()
+		storer.PreloadSession(createMockSession(sessionID, adminToken, true))
+		for _, body := range []string{`{"duration": "soon"}`, `{"duration": "-1h"}`, `{"duration": "10000h"}`} {
+			if w := extend(adminToken, body); w.Code != http.StatusBadRequest {
+				t.Errorf("%s: expected status %d, got %d", body, http.StatusBadRequest, w.Code)
+			}
+		}
+	})
+
+	t.Run("Unauthorized_User", func(t *testing.T) {
+		storer.Clear()
+		storer.PreloadSession(createMockSession(sessionID, adminToken, true))
+		if w := extend("invalid-token", ""); w.Code != http.StatusForbidden {
+			t.Errorf("Expected status %d, got %d", http.StatusForbidden, w.Code)
+		}
+	})
+
+	t.Run("SessionNotFound", func(t *testing.T) {
+		storer.Clear()
+		if w := extend(adminToken, ""); w.Code != http.StatusNotFound {
+			t.Errorf("Expected status %d, got %d", http.StatusNotFound, w.Code)
+		}
+	})
+}
+
+func TestCloseAndReopenSessionHandlers(t *testing.T) {
+	api, storer := setupTestAPI()
+	sessionID := "close-session"
+	adminToken := "secret-admin-token"
+
+	client := &ws.Client{SessionID: sessionID, Send: make(chan []byte, 4)}
+	api.Hub.Register(client)
+	defer api.Hub.Unregister(client)
+
+	call := func(handler http.HandlerFunc, action, token string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest(http.MethodPost, "/api/session/"+sessionID+"/"+action, nil)
+		r.SetPathValue("session_id", sessionID)
+		r.Header.Set("Authorization", "Bearer "+token)
+		handler(w, r)
+		return w
+	}
+
+	t.Run("Close_Admin", func(t *testing.T) {
+		storer.Clear()
+		storer.PreloadSession(createMockSession(sessionID, adminToken, true))
+		if w := call(api.CloseSessionHandler, "close", adminToken); w.Code != http.StatusNoContent {
+			t.Fatalf("Expected status %d, got %d", http.StatusNoContent, w.Code)
+		}
+		session, _ := storer.LoadSessionData(context.Background(), sessionID)
+		if session.IsActive {
+			t.Error("Expected session to be closed")
+		}
+		if len(session.Questions) != 2 {
+			t.Errorf("Expected questions to be kept, got %d", len(session.Questions))
+		}
+		if got := nextEvent(t, client); got != "SESSION_CLOSED" {
+			t.Errorf("Expected SESSION_CLOSED event, got %q", got)
+		}
+	})
+
+	t.Run("Close_AlreadyClosed_NoEvent", func(t *testing.T) {
+		storer.Clear()
+		storer.PreloadSession(createMockSession(sessionID, adminToken, false))
+		if w := call(api.CloseSessionHandler, "close", adminToken); w.Code != http.StatusNoContent {
+			t.Fatalf("Expected status %d, got %d", http.StatusNoContent, w.Code)
+		}
+		if got := nextEvent(t, client); got != "" {
+			t.Errorf("Expected no event, got %q", got)
+		}
+	})
+
+	t.Run("Reopen_Admin", func(t *testing.T) {
+		storer.Clear()
+		storer.PreloadSession(createMockSession(sessionID, adminToken, false))
+		if w := call(api.ReopenSessionHandler, "reopen", adminToken); w.Code != http.StatusNoContent {
+			t.Fatalf("Expected status %d, got %d", http.StatusNoContent, w.Code)
+		}
+		session, _ := storer.LoadSessionData(context.Background(), sessionID)
+		if !session.IsActive {
+			t.Error("Expected session to be reopened")
+		}
+		if got := nextEvent(t, client); got != "SESSION_REOPENED" {
+			t.Errorf("Expected SESSION_REOPENED event, got %q", got)
+		}
+	})
+
+	t.Run("Closed_RejectsQuestionsAndVotes", func(t *testing.T) {
+		storer.Clear()
+		storer.PreloadSession(createMockSession(sessionID, adminToken, true))
+		call(api.CloseSessionHandler, "close", adminToken)
+
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest(http.MethodPost, "/api/session/"+sessionID+"/questions", strings.NewReader(`{"text": "Too late?"}`))
+		r.SetPathValue("session_id", sessionID)
+		api.SubmitQuestionHandler(w, r)
+		if w.Code != http.StatusForbidden {
+			t.Errorf("Submit: expected status %d, got %d", http.StatusForbidden, w.Code)
+		}
+
+		questionID := "00000000-0000-0000-0000-000000000012"
+		w = httptest.NewRecorder()
+		r = httptest.NewRequest(http.MethodPut, "/api/session/"+sessionID+"/questions/"+questionID+"/vote", nil)
+		r.SetPathValue("session_id", sessionID)
+		r.SetPathValue("question_id", questionID)
+		r.AddCookie(&http.Cookie{Name: userSessionIDCookie, Value: "late-voter"})
+		api.VoteQuestionHandler(w, r)
+		if w.Code != http.StatusForbidden {
+			t.Errorf("Vote: expected status %d, got %d", http.StatusForbidden, w.Code)
+		}
+	})
+
+	t.Run("Unauthorized_User", func(t *testing.T) {
+		storer.Clear()
+		storer.PreloadSession(createMockSession(sessionID, adminToken, true))
+		if w := call(api.CloseSessionHandler, "close", "invalid-token"); w.Code != http.StatusForbidden {
+			t.Errorf("Expected status %d, got %d", http.StatusForbidden, w.Code)
+		}
+		session, _ := storer.LoadSessionData(context.Background(), sessionID)
+		if !session.IsActive {
+			t.Error("Session was closed by non-admin")
+		}
+	})
+
+	t.Run("SessionNotFound", func(t *testing.T) {
+		storer.Clear()
+		if w := call(api.ReopenSessionHandler, "reopen", adminToken); w.Code != http.StatusNotFound {
+			t.Errorf("Expected status %d, got %d", http.StatusNotFound, w.Code)
+		}
+	})
+}
+
+func TestSubmitQuestionHandler_BannedIP(t *testing.T) {
+	api, storer := setupTestAPI()
+	sessionID := "ban-submit-session"
+	bannedIP := "10.0.0.1"
+	session := createMockSession(sessionID, "admin-token", true)
+	session.BannedIPs = []string{bannedIP}
+	storer.PreloadSession(session)
+
+	t.Run("BannedIPRejected", func(t *testing.T) {
+		body := `{"text": "spam question"}`
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest(http.MethodPost, "/api/session/"+sessionID+"/questions", strings.NewReader(body))
 		r.SetPathValue("session_id", sessionID)
 		r.Header.Set("X-Real-IP", bannedIP)
 		api.SubmitQuestionHandler(w, r)
@@ -697,6 +1968,19 @@ func TestBanIPHandler(t *testing.T) {
 		}
 	})
 
+	t.Run("ImportedQuestion", func(t *testing.T) {
+		api, storer := setupTestAPI()
+		session := makeSession()
+		session.Questions[2].SubmitterIP = ""
+		storer.PreloadSession(session)
+
+		w := banRequest(api, qOther, adminIP)
+
+		if w.Code != http.StatusUnprocessableEntity {
+			t.Errorf("Expected status %d, got %d", http.StatusUnprocessableEntity, w.Code)
+		}
+	})
+
 	t.Run("AlreadyBanned_Idempotent", func(t *testing.T) {
 		api, storer := setupTestAPI()
 		session := makeSession()
@@ -1,6 +1,9 @@
 package models
 
-import "time"
+import (
+	"slices"
+	"time"
+)
 
 // SessionData represents the structure of the data stored in session${sessionId}.json
 type SessionData struct {
@@ -8,18 +11,108 @@ type SessionData struct {
 	SessionID    string     `json:"sessionId" bson:"sessionId"`
 	AdminToken   string     `json:"adminToken" bson:"adminToken"`
 	IsActive     bool       `json:"isActive" bson:"isActive"`
+	Version      int64      `json:"version" bson:"version"` // incremented on every successful update
 	CreatedAt    time.Time  `json:"createdAt" bson:"createdAt"`
+	ExpiresAt    time.Time  `json:"expiresAt" bson:"expiresAt"` // the session is deleted after this time
+	VotingMode   VotingMode `json:"votingMode" bson:"votingMode"`
+	VoteBudget   int        `json:"voteBudget" bson:"voteBudget"` // votes per participant in VotingModeBudget
+	Moderated    bool       `json:"moderated" bson:"moderated"`   // submitted questions are QuestionPending until approved
 	Questions    []Question `json:"questions" bson:"questions"`
 	BannedIPs    []string   `json:"-" bson:"bannedIPs"`
 }
 
+// VotesCast returns how many questions voterID has voted on, up or down.
+func (d *SessionData) VotesCast(voterID string) int {
+	n := 0
+	for _, q := range d.Questions {
+		if q.VoteOf(voterID) != 0 {
+			n++
+		}
+	}
+	return n
+}
+
+// VotingMode decides how participants vote in a session.
+type VotingMode string
+
+const (
+	// VotingModeUpvote allows one upvote per question. Sessions created before
+	// voting modes existed have an empty mode, which means the same.
+	VotingModeUpvote VotingMode = "upvote"
+	// VotingModeUpDown allows one upvote or downvote per question; Votes is
+	// the net score.
+	VotingModeUpDown VotingMode = "updown"
+	// VotingModeBudget allows one upvote per question, on at most VoteBudget
+	// questions per participant.
+	VotingModeBudget VotingMode = "budget"
+)
+
+// ArchivedSession is a read-only snapshot of a session that was ended by its admin.
+type ArchivedSession struct {
+	Session     SessionData `json:"session" bson:"session"`
+	ArchivedAt  time.Time   `json:"archivedAt" bson:"archivedAt"`
+	RetainUntil time.Time   `json:"retainUntil" bson:"retainUntil"` // the archive is deleted after this time
+}
+
 // Question represents a single question submitted by a user
 type Question struct {
-	ID          string   `json:"id" bson:"id"`
-	Text        string   `json:"text" bson:"text"`
-	Votes       int      `json:"votes" bson:"votes"`
-	Voters      []string `json:"voters" bson:"voters"` // userSessionIds who have voted
-	SubmitterIP string   `json:"-" bson:"submitterIP"`
+	ID          string         `json:"id" bson:"id"`
+	Text        string         `json:"text" bson:"text"`
+	Votes       int            `json:"votes" bson:"votes"`
+	Voters      []string       `json:"-" bson:"voters"`               // userSessionIds who have voted; never sent to clients
+	Downvoters  []string       `json:"-" bson:"downvoters,omitempty"` // userSessionIds who have downvoted, in VotingModeUpDown
+	SubmitterIP string         `json:"-" bson:"submitterIP"`
+	CreatedAt   time.Time      `json:"createdAt" bson:"createdAt"`
+	Status      QuestionStatus `json:"status" bson:"status,omitempty"`
+}
+
+// QuestionStatus is where a question is in its lifecycle, as set by the admin.
+type QuestionStatus string
+
+const (
+	// QuestionOpen is the status of new questions. Questions submitted before
+	// statuses existed have an empty status, which means the same.
+	QuestionOpen QuestionStatus = "open"
+	// QuestionPinned highlights a question above the open ones.
+	QuestionPinned QuestionStatus = "pinned"
+	// QuestionAnswered marks a question the speakers have covered.
+	QuestionAnswered QuestionStatus = "answered"
+	// QuestionHidden removes a question from the participants' view.
+	QuestionHidden QuestionStatus = "hidden"
+	// QuestionPending is the status of questions submitted to a moderated
+	// session until the admin approves them. Only the admin sees them.
+	QuestionPending QuestionStatus = "pending"
+)
+
+// Valid reports whether s is one of the known statuses.
+func (s QuestionStatus) Valid() bool {
+	switch s {
+	case QuestionOpen, QuestionPinned, QuestionAnswered, QuestionHidden, QuestionPending:
+		return true
+	}
+	return false
+}
+
+// Visible reports whether participants can see the question; hidden and
+// pending questions are shown to the admin only.
+func (q *Question) Visible() bool {
+	return q.Status != QuestionHidden && q.Status != QuestionPending
+}
+
+// VoteOf returns voterID's vote on the question: 1, -1, or 0 if none.
+func (q *Question) VoteOf(voterID string) int {
+	switch {
+	case slices.Contains(q.Voters, voterID):
+		return 1
+	case slices.Contains(q.Downvoters, voterID):
+		return -1
+	}
+	return 0
+}
+
+// QuestionUpdate is used for the PATCH request body
+type QuestionUpdate struct {
+	Status QuestionStatus `json:"status"`
 }
 
 // QuestionSubmission is used for the POST request body
@@ -29,5 +122,8 @@ type QuestionSubmission struct {
 
 // CreateSessionRequest is used for the POST /api/session request body
 type CreateSessionRequest struct {
-	SessionID string `json:"sessionId"`
+	SessionID  string     `json:"sessionId"`
+	VotingMode VotingMode `json:"votingMode"` // defaults to VotingModeUpvote
+	VoteBudget int        `json:"voteBudget"` // required for VotingModeBudget
+	Moderated  bool       `json:"moderated"`  // hold questions for approval
 }
@@ -0,0 +1,236 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"embed"
+	"fmt"
+	"io/fs"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+//go:embed migrations
+var migrationFiles embed.FS
+
+// Migration is a forward-only schema change loaded from an embedded SQL file
+// named NNNN_description.sql.
+type Migration struct {
+	Version int
+	Name    string
+	SQL     string
+}
+
+// MigrationStatus reports whether a migration has been applied.
+type MigrationStatus struct {
+	Migration
+	AppliedAt time.Time // zero while the migration is pending
+}
+
+// Applied reports whether the migration has been applied.
+func (s MigrationStatus) Applied() bool {
+	return !s.AppliedAt.IsZero()
+}
+
+// loadMigrations reads the migrations embedded under migrations/<dir>, ordered by version.
+func loadMigrations(dir string) ([]Migration, error) {
+	root := path.Join("migrations", dir)
+	entries, err := fs.ReadDir(migrationFiles, root)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read migrations: %w", err)
+	}
+	var migrations []Migration
+	for _, e := range entries {
+		if e.IsDir() || !strings.HasSuffix(e.Name(), ".sql") {
+			continue
+		}
+		base := strings.TrimSuffix(e.Name(), ".sql")
+		prefix, name, ok := strings.Cut(base, "_")
+		version, err := strconv.Atoi(prefix)
+		if !ok || err != nil {
+			return nil, fmt.Errorf("invalid migration file name %q", e.Name())
+		}
+		body, err := fs.ReadFile(migrationFiles, path.Join(root, e.Name()))
+		if err != nil {
+			return nil, fmt.Errorf("failed to read migration %q: %w", e.Name(), err)
+		}
+		migrations = append(migrations, Migration{Version: version, Name: name, SQL: string(body)})
+	}
+	sort.Slice(migrations, func(i, j int) bool { return migrations[i].Version < migrations[j].Version })
+	for i := 1; i < len(migrations); i++ {
+		if migrations[i].Version == migrations[i-1].Version {
+			return nil, fmt.Errorf("duplicate migration version %d", migrations[i].Version)
+		}
+	}
+	return migrations, nil
+}
+
+// migrator applies embedded migrations to a database/sql database and records
+// them in the schema_migrations table.
+type migrator struct {
+	db      *sql.DB
+	dialect *sqlDialect
+	dir     string
+	// baseline returns the version an existing database without a
+	// schema_migrations table is already at, so that it is not migrated twice.
+	baseline func(ctx context.Context, tx *sql.Tx) (int, error)
+}
+
+// begin starts the transaction a migration run works in. It takes the
+// dialect's migration lock, so that instances starting together on the same
+// database migrate it one after the other, then creates schema_migrations if
+// needed, recording the baseline migrations of a pre-existing database as
+// applied.
+func (m *migrator) begin(ctx context.Context, migrations []Migration) (*sql.Tx, error) {
+	tx, err := m.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin migrations: %w", err)
+	}
+	if err := m.init(ctx, tx, migrations); err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+	return tx, nil
+}
+
+func (m *migrator) init(ctx context.Context, tx *sql.Tx, migrations []Migration) error {
+	if m.dialect.migrationLock != "" {
+		if _, err := tx.ExecContext(ctx, m.dialect.migrationLock); err != nil {
+			return fmt.Errorf("failed to lock migrations: %w", err)
+		}
+	}
+
+	var exists int
+	err := tx.QueryRowContext(ctx, m.dialect.rebind(m.dialect.tableExistsQuery), "schema_migrations").Scan(&exists)
+	if err != nil {
+		return fmt.Errorf("failed to inspect schema: %w", err)
+	}
+	if exists > 0 {
+		return nil
+	}
+
+	baseline, err := m.baseline(ctx, tx)
+	if err != nil {
+		return err
+	}
+	_, err = tx.ExecContext(ctx, `
+		CREATE TABLE IF NOT EXISTS schema_migrations (
+			version    INTEGER PRIMARY KEY,
+			name       TEXT NOT NULL,
+			applied_at `+m.dialect.timestampType+` NOT NULL
+		)
+	`)
+	if err != nil {
+		return fmt.Errorf("failed to create schema_migrations: %w", err)
+	}
+	now := time.Now().UTC().Format(time.RFC3339)
+	for _, mig := range migrations {
+		if mig.Version > baseline {
+			break
+		}
+		if err := m.record(ctx, tx, mig, now); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// applied returns the applied time of each applied migration, by version.
+func (m *migrator) applied(ctx context.Context, tx *sql.Tx) (map[int]time.Time, error) {
+	rows, err := tx.QueryContext(ctx, `SELECT version, applied_at FROM schema_migrations`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read schema_migrations: %w", err)
+	}
+	defer rows.Close()
+	applied := make(map[int]time.Time)
+	for rows.Next() {
+		var version int
+		var appliedAt string
+		if err := rows.Scan(&version, &appliedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan schema_migrations: %w", err)
+		}
+		applied[version], _ = time.Parse(time.RFC3339, appliedAt)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read schema_migrations: %w", err)
+	}
+	return applied, nil
+}
+
+// status returns every known migration together with its applied time.
+func (m *migrator) status(ctx context.Context) ([]MigrationStatus, error) {
+	migrations, err := loadMigrations(m.dir)
+	if err != nil {
+		return nil, err
+	}
+	tx, err := m.begin(ctx, migrations)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+	applied, err := m.applied(ctx, tx)
+	if err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to create schema_migrations: %w", err)
+	}
+
+	statuses := make([]MigrationStatus, len(migrations))
+	for i, mig := range migrations {
+		statuses[i] = MigrationStatus{Migration: mig, AppliedAt: applied[mig.Version]}
+	}
+	return statuses, nil
+}
+
+// up applies all pending migrations in order and returns the ones it applied.
+// They are applied in one transaction, under the migration lock, so either
+// all of them are or none; the versions are read under the lock, so that an
+// instance that waited for another does not apply them again.
+func (m *migrator) up(ctx context.Context) ([]Migration, error) {
+	migrations, err := loadMigrations(m.dir)
+	if err != nil {
+		return nil, err
+	}
+	tx, err := m.begin(ctx, migrations)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+	done, err := m.applied(ctx, tx)
+	if err != nil {
+		return nil, err
+	}
+
+	var applied []Migration
+	now := time.Now().UTC().Format(time.RFC3339)
+	for _, mig := range migrations {
+		if _, ok := done[mig.Version]; ok {
+			continue
+		}
+		if _, err := tx.ExecContext(ctx, mig.SQL); err != nil {
+			return nil, fmt.Errorf("migration %04d_%s failed: %w", mig.Version, mig.Name, err)
+		}
+		if err := m.record(ctx, tx, mig, now); err != nil {
+			return nil, err
+		}
+		applied = append(applied, mig)
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit migrations: %w", err)
+	}
+	return applied, nil
+}
+
+func (m *migrator) record(ctx context.Context, tx *sql.Tx, mig Migration, appliedAt string) error {
+	_, err := tx.ExecContext(ctx, m.dialect.rebind(
+		`INSERT INTO schema_migrations (version, name, applied_at) VALUES (?, ?, ?)`),
+		mig.Version, mig.Name, appliedAt)
+	if err != nil {
+		return fmt.Errorf("failed to record migration %04d: %w", mig.Version, err)
+	}
+	return nil
+}
@@ -0,0 +1,34 @@
+-- Sessions, questions, votes and bans, mirroring the SQLite schema. Child rows
+-- are removed through ON DELETE CASCADE when their session or question is deleted.
+CREATE TABLE sessions (
+	session_id    TEXT PRIMARY KEY,
+	session_title TEXT NOT NULL DEFAULT '',
+	admin_token   TEXT NOT NULL DEFAULT '',
+	is_active     BOOLEAN NOT NULL DEFAULT TRUE,
+	created_at    TIMESTAMPTZ NOT NULL,
+	version       BIGINT NOT NULL DEFAULT 0
+);
+CREATE INDEX idx_sessions_created_at ON sessions(created_at);
+
+CREATE TABLE questions (
+	id           TEXT PRIMARY KEY,
+	session_id   TEXT NOT NULL REFERENCES sessions(session_id) ON DELETE CASCADE,
+	position     INTEGER NOT NULL,
+	text         TEXT NOT NULL,
+	submitter_ip TEXT NOT NULL DEFAULT '',
+	created_at   TIMESTAMPTZ NOT NULL
+);
+CREATE INDEX idx_questions_session ON questions(session_id, position);
+
+CREATE TABLE votes (
+	question_id TEXT NOT NULL REFERENCES questions(id) ON DELETE CASCADE,
+	voter_id    TEXT NOT NULL,
+	created_at  TIMESTAMPTZ NOT NULL,
+	PRIMARY KEY (question_id, voter_id)
+);
+
+CREATE TABLE bans (
+	session_id TEXT NOT NULL REFERENCES sessions(session_id) ON DELETE CASCADE,
+	ip         TEXT NOT NULL,
+	PRIMARY KEY (session_id, ip)
+);
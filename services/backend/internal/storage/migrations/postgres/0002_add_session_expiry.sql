@@ -0,0 +1,10 @@
+-- Sessions expire at an explicit time instead of 24 hours after creation.
+-- Existing sessions keep the deadline they had under the old rule.
+ALTER TABLE sessions ADD COLUMN expires_at TIMESTAMPTZ;
+
+UPDATE sessions SET expires_at = created_at + INTERVAL '24 hours';
+
+ALTER TABLE sessions ALTER COLUMN expires_at SET NOT NULL;
+
+DROP INDEX idx_sessions_created_at;
+CREATE INDEX idx_sessions_expires_at ON sessions(expires_at);
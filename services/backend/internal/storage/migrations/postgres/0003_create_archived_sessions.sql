@@ -0,0 +1,8 @@
+-- Ended sessions are kept as read-only JSON documents until retain_until.
+CREATE TABLE archived_sessions (
+	session_id   TEXT PRIMARY KEY,
+	data         TEXT NOT NULL,
+	archived_at  TIMESTAMPTZ NOT NULL,
+	retain_until TIMESTAMPTZ NOT NULL
+);
+CREATE INDEX idx_archived_sessions_retain_until ON archived_sessions(retain_until);
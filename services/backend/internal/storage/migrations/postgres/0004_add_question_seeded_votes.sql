@@ -0,0 +1,3 @@
+-- Imported questions can start with votes that have no recorded voter.
+-- A question's vote count is seeded_votes plus its rows in votes.
+ALTER TABLE questions ADD COLUMN seeded_votes INTEGER NOT NULL DEFAULT 0;
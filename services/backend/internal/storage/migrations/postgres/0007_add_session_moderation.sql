@@ -0,0 +1,2 @@
+-- Moderated sessions hold submitted questions as pending until the admin approves them.
+ALTER TABLE sessions ADD COLUMN moderated BOOLEAN NOT NULL DEFAULT FALSE;
@@ -0,0 +1,6 @@
+-- Original layout: each session is stored as a JSON document.
+CREATE TABLE IF NOT EXISTS sessions (
+	session_id TEXT PRIMARY KEY,
+	created_at DATETIME NOT NULL,
+	data       TEXT NOT NULL
+);
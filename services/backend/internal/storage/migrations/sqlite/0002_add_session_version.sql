@@ -0,0 +1,2 @@
+-- Version counter for optimistic concurrency control.
+ALTER TABLE sessions ADD COLUMN version INTEGER NOT NULL DEFAULT 0;
@@ -0,0 +1,56 @@
+-- Split the JSON documents into sessions, questions, votes and bans tables.
+-- Child rows are removed through ON DELETE CASCADE when their session or
+-- question is deleted. The JSON documents never contained submitter IPs or
+-- bans (both are hidden from JSON), so there is nothing to carry over for those.
+ALTER TABLE sessions RENAME TO sessions_blob;
+
+CREATE TABLE sessions (
+	session_id    TEXT PRIMARY KEY,
+	session_title TEXT NOT NULL DEFAULT '',
+	admin_token   TEXT NOT NULL DEFAULT '',
+	is_active     INTEGER NOT NULL DEFAULT 1,
+	created_at    DATETIME NOT NULL,
+	version       INTEGER NOT NULL DEFAULT 0
+);
+
+CREATE TABLE questions (
+	id           TEXT PRIMARY KEY,
+	session_id   TEXT NOT NULL REFERENCES sessions(session_id) ON DELETE CASCADE,
+	position     INTEGER NOT NULL,
+	text         TEXT NOT NULL,
+	submitter_ip TEXT NOT NULL DEFAULT '',
+	created_at   DATETIME NOT NULL
+);
+CREATE INDEX idx_questions_session ON questions(session_id, position);
+
+CREATE TABLE votes (
+	question_id TEXT NOT NULL REFERENCES questions(id) ON DELETE CASCADE,
+	voter_id    TEXT NOT NULL,
+	created_at  DATETIME NOT NULL,
+	PRIMARY KEY (question_id, voter_id)
+);
+
+CREATE TABLE bans (
+	session_id TEXT NOT NULL REFERENCES sessions(session_id) ON DELETE CASCADE,
+	ip         TEXT NOT NULL,
+	PRIMARY KEY (session_id, ip)
+);
+
+INSERT INTO sessions (session_id, session_title, admin_token, is_active, created_at, version)
+SELECT session_id,
+       COALESCE(json_extract(data, '$.sessionTitle'), ''),
+       COALESCE(json_extract(data, '$.adminToken'), ''),
+       COALESCE(json_extract(data, '$.isActive'), 1),
+       created_at,
+       version
+FROM sessions_blob;
+
+INSERT INTO questions (id, session_id, position, text, created_at)
+SELECT json_extract(q.value, '$.id'), s.session_id, q.key, json_extract(q.value, '$.text'), s.created_at
+FROM sessions_blob s, json_each(s.data, '$.questions') q;
+
+INSERT OR IGNORE INTO votes (question_id, voter_id, created_at)
+SELECT json_extract(q.value, '$.id'), v.value, s.created_at
+FROM sessions_blob s, json_each(s.data, '$.questions') q, json_each(q.value, '$.voters') v;
+
+DROP TABLE sessions_blob;
@@ -0,0 +1,7 @@
+-- Sessions expire at an explicit time instead of 24 hours after creation.
+-- Existing sessions keep the deadline they had under the old rule.
+ALTER TABLE sessions ADD COLUMN expires_at DATETIME NOT NULL DEFAULT '';
+
+UPDATE sessions SET expires_at = strftime('%Y-%m-%dT%H:%M:%SZ', created_at, '+24 hours');
+
+CREATE INDEX idx_sessions_expires_at ON sessions(expires_at);
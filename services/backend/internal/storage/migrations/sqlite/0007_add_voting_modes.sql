@@ -0,0 +1,6 @@
+-- Sessions choose a voting mode; an empty mode is the original upvote-only one.
+ALTER TABLE sessions ADD COLUMN voting_mode TEXT NOT NULL DEFAULT '';
+ALTER TABLE sessions ADD COLUMN vote_budget INTEGER NOT NULL DEFAULT 0;
+
+-- A vote is +1, or -1 for a downvote in up/down sessions.
+ALTER TABLE votes ADD COLUMN value INTEGER NOT NULL DEFAULT 1;
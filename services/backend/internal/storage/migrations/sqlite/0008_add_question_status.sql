@@ -0,0 +1,2 @@
+-- Admins move questions between open, pinned, answered and hidden.
+ALTER TABLE questions ADD COLUMN status TEXT NOT NULL DEFAULT 'open';
@@ -4,16 +4,138 @@ import (
 	"context"
 	"errors"
 	"question-voting-app/internal/models"
+	"time"
 )
 
 var ErrNotFound = errors.New("not found")
 var ErrDuplicateKey = errors.New("duplicate key")
 
+// ErrConflict is returned by UpdateSessionData when the stored session has been
+// modified since it was loaded, i.e. its Version no longer matches.
+var ErrConflict = errors.New("version conflict")
+
+// ErrAlreadyVoted is returned by AddVote when the voter already voted on the question.
+var ErrAlreadyVoted = errors.New("already voted")
+
+// ErrNotVoted is returned by RemoveVote when the voter has no vote on the question.
+var ErrNotVoted = errors.New("not voted")
+
+// ErrNoVotesLeft is returned by AddVote and AddDownvote when the voter has used
+// up the session's vote budget.
+var ErrNoVotesLeft = errors.New("no votes left")
+
+// ErrLimitReached is returned by AddQuestion when the session is full.
+var ErrLimitReached = errors.New("limit reached")
+
+// DefaultSessionTTL is the lifetime of sessions created without an ExpiresAt.
+const DefaultSessionTTL = 24 * time.Hour
+
+// cleanupInterval is how often the backends without native TTL support delete
+// expired sessions. It matches the period of MongoDB's TTL monitor.
+const cleanupInterval = time.Minute
+
+// MaxConflictRetries bounds how often Mutate reloads a session after ErrConflict.
+const MaxConflictRetries = 5
+
 // Storer defines the interface for session data storage.
+//
+// UpdateSessionData performs an optimistic compare-and-swap: it only succeeds if
+// the stored Version still equals data.Version, and increments data.Version on
+// success. Callers should reload and retry when it returns ErrConflict.
+//
+// Sessions are deleted once their ExpiresAt has passed. CreateSessionData sets
+// a zero ExpiresAt to DefaultSessionTTL after CreatedAt.
+//
+// The remaining methods change part of a session atomically without rewriting
+// the whole document, so concurrent requests need no application-level
+// locking. They also increment the session Version.
 type Storer interface {
 	LoadSessionData(ctx context.Context, sessionID string) (*models.SessionData, error)
 	CreateSessionData(ctx context.Context, data *models.SessionData) error
 	UpdateSessionData(ctx context.Context, data *models.SessionData) error
 	DeleteSessionData(ctx context.Context, sessionID string) error
 	ConfigureIndexes(ctx context.Context) error
+
+	// AddQuestion appends q to the session unless it already holds maxQuestions.
+	AddQuestion(ctx context.Context, sessionID string, q models.Question, maxQuestions int) error
+	// AddQuestions appends all of qs, or none of them if the session would
+	// then hold more than maxQuestions.
+	AddQuestions(ctx context.Context, sessionID string, qs []models.Question, maxQuestions int) error
+	// AddVote records voterID's vote and returns the updated question. It
+	// fails with ErrNoVotesLeft once voterID has voted on VoteBudget questions.
+	AddVote(ctx context.Context, sessionID, questionID, voterID string) (*models.Question, error)
+	// AddDownvote records voterID's downvote and returns the updated question.
+	AddDownvote(ctx context.Context, sessionID, questionID, voterID string) (*models.Question, error)
+	// RemoveVote retracts voterID's vote and returns the updated question.
+	RemoveVote(ctx context.Context, sessionID, questionID, voterID string) (*models.Question, error)
+	// SetQuestionStatus changes a question's status and returns the updated
+	// question.
+	SetQuestionStatus(ctx context.Context, sessionID, questionID string, status models.QuestionStatus) (*models.Question, error)
+	DeleteQuestion(ctx context.Context, sessionID, questionID string) error
+	// BanSubmitter bans ip and deletes its questions, returning their IDs.
+	BanSubmitter(ctx context.Context, sessionID, ip string) ([]string, error)
+
+	// ArchiveSession moves a session into the archive, replacing any earlier
+	// archive with the same ID, and keeps it until retainUntil.
+	ArchiveSession(ctx context.Context, sessionID string, retainUntil time.Time) error
+	LoadArchivedSession(ctx context.Context, sessionID string) (*models.ArchivedSession, error)
+}
+
+// setDefaultExpiry fills in ExpiresAt for sessions created without one.
+func setDefaultExpiry(data *models.SessionData) {
+	if data.ExpiresAt.IsZero() {
+		data.ExpiresAt = data.CreatedAt.Add(DefaultSessionTTL)
+	}
+}
+
+// NewArchivedSession builds the archive of data. Archives are kept long after
+// the session ends, so they drop the submitter IPs and bans.
+func NewArchivedSession(data *models.SessionData, retainUntil time.Time) *models.ArchivedSession {
+	archived := &models.ArchivedSession{
+		Session:     *data,
+		ArchivedAt:  time.Now().UTC().Truncate(time.Second),
+		RetainUntil: retainUntil.UTC().Truncate(time.Second),
+	}
+	archived.Session.BannedIPs = nil
+	archived.Session.Questions = make([]models.Question, len(data.Questions))
+	for i, q := range data.Questions {
+		q.SubmitterIP = ""
+		archived.Session.Questions[i] = q
+	}
+	return archived
+}
+
+// findQuestion returns a pointer to the question with the given ID, or nil.
+func findQuestion(data *models.SessionData, questionID string) *models.Question {
+	for i := range data.Questions {
+		if data.Questions[i].ID == questionID {
+			return &data.Questions[i]
+		}
+	}
+	return nil
+}
+
+// Mutate runs a load-mutate-save cycle on a session, reloading and retrying
+// when UpdateSessionData reports ErrConflict. mutate may therefore run more
+// than once and must not keep state between calls.
+func Mutate(ctx context.Context, s Storer, sessionID string, mutate func(*models.SessionData) error) (*models.SessionData, error) {
+	var err error
+	for i := 0; i < MaxConflictRetries; i++ {
+		var data *models.SessionData
+		data, err = s.LoadSessionData(ctx, sessionID)
+		if err != nil {
+			return nil, err
+		}
+		if err = mutate(data); err != nil {
+			return nil, err
+		}
+		err = s.UpdateSessionData(ctx, data)
+		if err == nil {
+			return data, nil
+		}
+		if !errors.Is(err, ErrConflict) {
+			return nil, err
+		}
+	}
+	return nil, err
 }
@@ -0,0 +1,415 @@
+package storage
+
+import (
+	"context"
+	"encoding/gob"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"sync"
+	"time"
+
+	"question-voting-app/internal/models"
+)
+
+// MemoryStorage implements the Storer interface with in-process maps. It is
+// safe for concurrent use; callers always receive deep copies, so they cannot
+// mutate stored state behind the lock.
+//
+// If a snapshot file is configured, the sessions and archives are written to it
+// periodically and on Close, and restored from it on startup. Snapshots are
+// best effort: changes made since the last snapshot are lost when the process
+// dies without closing the storage.
+type MemoryStorage struct {
+	mu       sync.RWMutex
+	sessions map[string]*models.SessionData
+	archive  map[string]*models.ArchivedSession
+
+	snapshotFile     string
+	snapshotInterval time.Duration
+}
+
+// NewMemoryStorage returns an empty MemoryStorage. If snapshotFile is not
+// empty, sessions are restored from it when it exists and written back every
+// snapshotInterval once ConfigureIndexes has been called.
+func NewMemoryStorage(snapshotFile string, snapshotInterval time.Duration) (*MemoryStorage, error) {
+	s := &MemoryStorage{
+		sessions:         make(map[string]*models.SessionData),
+		archive:          make(map[string]*models.ArchivedSession),
+		snapshotFile:     snapshotFile,
+		snapshotInterval: snapshotInterval,
+	}
+	if snapshotFile == "" {
+		return s, nil
+	}
+	f, err := os.Open(snapshotFile)
+	if os.IsNotExist(err) {
+		return s, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to open snapshot: %w", err)
+	}
+	defer f.Close()
+	// gob rather than JSON: the models hide submitter IPs and bans from JSON.
+	var snap memorySnapshot
+	if err := gob.NewDecoder(f).Decode(&snap); err != nil {
+		return nil, fmt.Errorf("failed to read snapshot: %w", err)
+	}
+	if snap.Sessions != nil {
+		s.sessions = snap.Sessions
+	}
+	if snap.Archive != nil {
+		s.archive = snap.Archive
+	}
+	return s, nil
+}
+
+// memorySnapshot is the on-disk snapshot format.
+type memorySnapshot struct {
+	Sessions map[string]*models.SessionData
+	Archive  map[string]*models.ArchivedSession
+}
+
+// ConfigureIndexes starts the background expiry cleanup and snapshot goroutines.
+func (s *MemoryStorage) ConfigureIndexes(ctx context.Context) error {
+	go s.runCleanup()
+	if s.snapshotFile != "" && s.snapshotInterval > 0 {
+		go s.runSnapshots()
+	}
+	fmt.Println("In-memory storage configured successfully.")
+	return nil
+}
+
+// runCleanup periodically deletes expired sessions and archives.
+func (s *MemoryStorage) runCleanup() {
+	ticker := time.NewTicker(cleanupInterval)
+	defer ticker.Stop()
+	for range ticker.C {
+		s.Cleanup()
+	}
+}
+
+// Cleanup deletes all expired sessions, and all archives past their retention.
+func (s *MemoryStorage) Cleanup() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	for id, data := range s.sessions {
+		if expired(data) {
+			delete(s.sessions, id)
+		}
+	}
+	for id, archived := range s.archive {
+		if now.After(archived.RetainUntil) {
+			delete(s.archive, id)
+		}
+	}
+}
+
+func (s *MemoryStorage) runSnapshots() {
+	ticker := time.NewTicker(s.snapshotInterval)
+	defer ticker.Stop()
+	for range ticker.C {
+		if err := s.Snapshot(); err != nil {
+			fmt.Printf("In-memory snapshot error: %v\n", err)
+		}
+	}
+}
+
+// Close writes a final snapshot, so that a clean shutdown loses no changes.
+func (s *MemoryStorage) Close() error {
+	return s.Snapshot()
+}
+
+// Snapshot writes all sessions and archives to the snapshot file, replacing it atomically.
+func (s *MemoryStorage) Snapshot() error {
+	if s.snapshotFile == "" {
+		return nil
+	}
+	f, err := os.CreateTemp(filepath.Dir(s.snapshotFile), filepath.Base(s.snapshotFile)+".tmp*")
+	if err != nil {
+		return fmt.Errorf("failed to create snapshot: %w", err)
+	}
+	defer os.Remove(f.Name())
+
+	s.mu.RLock()
+	err = gob.NewEncoder(f).Encode(memorySnapshot{Sessions: s.sessions, Archive: s.archive})
+	s.mu.RUnlock()
+	if err != nil {
+		f.Close()
+		return fmt.Errorf("failed to write snapshot: %w", err)
+	}
+	if err := f.Close(); err != nil {
+		return fmt.Errorf("failed to write snapshot: %w", err)
+	}
+	if err := os.Rename(f.Name(), s.snapshotFile); err != nil {
+		return fmt.Errorf("failed to replace snapshot: %w", err)
+	}
+	return nil
+}
+
+// expired reports whether a session is past its ExpiresAt, even if Cleanup
+// has not removed it yet.
+func expired(data *models.SessionData) bool {
+	return time.Now().After(data.ExpiresAt)
+}
+
+// session returns the stored session, treating expired sessions as missing.
+// The caller must hold s.mu.
+func (s *MemoryStorage) session(sessionID string) (*models.SessionData, error) {
+	data, exists := s.sessions[sessionID]
+	if !exists || expired(data) {
+		return nil, fmt.Errorf("session not found: %w", ErrNotFound)
+	}
+	return data, nil
+}
+
+func (s *MemoryStorage) LoadSessionData(ctx context.Context, sessionID string) (*models.SessionData, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	data, err := s.session(sessionID)
+	if err != nil {
+		return nil, err
+	}
+	return copySessionData(data), nil
+}
+
+func (s *MemoryStorage) CreateSessionData(ctx context.Context, data *models.SessionData) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, err := s.session(data.SessionID); err == nil {
+		return fmt.Errorf("session already exists: %w", ErrDuplicateKey)
+	}
+	setDefaultExpiry(data)
+	s.sessions[data.SessionID] = copySessionData(data)
+	return nil
+}
+
+// UpdateSessionData replaces the stored session, provided its version still
+// matches data.Version.
+func (s *MemoryStorage) UpdateSessionData(ctx context.Context, data *models.SessionData) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	current, err := s.session(data.SessionID)
+	if err != nil {
+		return err
+	}
+	if current.Version != data.Version {
+		return fmt.Errorf("session was modified concurrently: %w", ErrConflict)
+	}
+	data.Version++
+	s.sessions[data.SessionID] = copySessionData(data)
+	return nil
+}
+
+func (s *MemoryStorage) DeleteSessionData(ctx context.Context, sessionID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.sessions, sessionID)
+	return nil
+}
+
+// ArchiveSession moves the session into the archive map.
+func (s *MemoryStorage) ArchiveSession(ctx context.Context, sessionID string, retainUntil time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	data, err := s.session(sessionID)
+	if err != nil {
+		return err
+	}
+	s.archive[sessionID] = NewArchivedSession(copySessionData(data), retainUntil)
+	delete(s.sessions, sessionID)
+	return nil
+}
+
+// LoadArchivedSession returns a copy of the archive, treating archives past
+// their retention as missing.
+func (s *MemoryStorage) LoadArchivedSession(ctx context.Context, sessionID string) (*models.ArchivedSession, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	archived, exists := s.archive[sessionID]
+	if !exists || time.Now().After(archived.RetainUntil) {
+		return nil, fmt.Errorf("archived session not found: %w", ErrNotFound)
+	}
+	archivedCopy := *archived
+	archivedCopy.Session = *copySessionData(&archived.Session)
+	return &archivedCopy, nil
+}
+
+// AddQuestion appends q to the session unless it already holds maxQuestions.
+func (s *MemoryStorage) AddQuestion(ctx context.Context, sessionID string, q models.Question, maxQuestions int) error {
+	return s.AddQuestions(ctx, sessionID, []models.Question{q}, maxQuestions)
+}
+
+// AddQuestions appends qs to the session unless it would then hold more than
+// maxQuestions.
+func (s *MemoryStorage) AddQuestions(ctx context.Context, sessionID string, qs []models.Question, maxQuestions int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	data, err := s.session(sessionID)
+	if err != nil {
+		return err
+	}
+	if len(data.Questions)+len(qs) > maxQuestions {
+		return fmt.Errorf("session has %d questions: %w", len(data.Questions), ErrLimitReached)
+	}
+	for _, q := range qs {
+		data.Questions = append(data.Questions, *copyQuestion(q))
+	}
+	data.Version++
+	return nil
+}
+
+// AddVote records voterID's vote and returns a copy of the updated question.
+func (s *MemoryStorage) AddVote(ctx context.Context, sessionID, questionID, voterID string) (*models.Question, error) {
+	return s.addVote(sessionID, questionID, voterID, 1)
+}
+
+// AddDownvote records voterID's downvote and returns a copy of the updated question.
+func (s *MemoryStorage) AddDownvote(ctx context.Context, sessionID, questionID, voterID string) (*models.Question, error) {
+	return s.addVote(sessionID, questionID, voterID, -1)
+}
+
+func (s *MemoryStorage) addVote(sessionID, questionID, voterID string, value int) (*models.Question, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	data, err := s.session(sessionID)
+	if err != nil {
+		return nil, err
+	}
+	q := findQuestion(data, questionID)
+	if q == nil {
+		return nil, fmt.Errorf("question not found: %w", ErrNotFound)
+	}
+	if q.VoteOf(voterID) != 0 {
+		return nil, fmt.Errorf("voter %q: %w", voterID, ErrAlreadyVoted)
+	}
+	if data.VoteBudget > 0 && data.VotesCast(voterID) >= data.VoteBudget {
+		return nil, fmt.Errorf("voter %q already cast %d votes: %w", voterID, data.VoteBudget, ErrNoVotesLeft)
+	}
+	if value < 0 {
+		q.Downvoters = append(q.Downvoters, voterID)
+	} else {
+		q.Voters = append(q.Voters, voterID)
+	}
+	q.Votes += value
+	data.Version++
+	return copyQuestion(*q), nil
+}
+
+// RemoveVote retracts voterID's vote and returns a copy of the updated question.
+func (s *MemoryStorage) RemoveVote(ctx context.Context, sessionID, questionID, voterID string) (*models.Question, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	data, err := s.session(sessionID)
+	if err != nil {
+		return nil, err
+	}
+	q := findQuestion(data, questionID)
+	if q == nil {
+		return nil, fmt.Errorf("question not found: %w", ErrNotFound)
+	}
+	switch q.VoteOf(voterID) {
+	case 1:
+		q.Voters = slices.DeleteFunc(q.Voters, func(v string) bool { return v == voterID })
+		q.Votes--
+	case -1:
+		q.Downvoters = slices.DeleteFunc(q.Downvoters, func(v string) bool { return v == voterID })
+		q.Votes++
+	default:
+		return nil, fmt.Errorf("voter %q: %w", voterID, ErrNotVoted)
+	}
+	data.Version++
+	return copyQuestion(*q), nil
+}
+
+// SetQuestionStatus changes the question's status in place.
+func (s *MemoryStorage) SetQuestionStatus(ctx context.Context, sessionID, questionID string, status models.QuestionStatus) (*models.Question, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	data, err := s.session(sessionID)
+	if err != nil {
+		return nil, err
+	}
+	q := findQuestion(data, questionID)
+	if q == nil {
+		return nil, fmt.Errorf("question not found: %w", ErrNotFound)
+	}
+	q.Status = status
+	data.Version++
+	return copyQuestion(*q), nil
+}
+
+// DeleteQuestion removes the question from the session.
+func (s *MemoryStorage) DeleteQuestion(ctx context.Context, sessionID, questionID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	data, err := s.session(sessionID)
+	if err != nil {
+		return err
+	}
+	i := slices.IndexFunc(data.Questions, func(q models.Question) bool { return q.ID == questionID })
+	if i < 0 {
+		return fmt.Errorf("question not found: %w", ErrNotFound)
+	}
+	data.Questions = slices.Delete(data.Questions, i, i+1)
+	data.Version++
+	return nil
+}
+
+// BanSubmitter bans ip and deletes its questions, returning their IDs.
+func (s *MemoryStorage) BanSubmitter(ctx context.Context, sessionID, ip string) ([]string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	data, err := s.session(sessionID)
+	if err != nil {
+		return nil, err
+	}
+	if !slices.Contains(data.BannedIPs, ip) {
+		data.BannedIPs = append(data.BannedIPs, ip)
+	}
+	var removed []string
+	data.Questions = slices.DeleteFunc(data.Questions, func(q models.Question) bool {
+		if q.SubmitterIP == ip {
+			removed = append(removed, q.ID)
+			return true
+		}
+		return false
+	})
+	data.Version++
+	return removed, nil
+}
+
+// copySessionData deep-copies a session so that the copy shares no slices
+// with the original. Nil question and voter lists become empty ones, as the
+// other backends return them.
+func copySessionData(data *models.SessionData) *models.SessionData {
+	dataCopy := *data
+	dataCopy.BannedIPs = slices.Clone(data.BannedIPs)
+	dataCopy.Questions = make([]models.Question, len(data.Questions))
+	for i, q := range data.Questions {
+		dataCopy.Questions[i] = *copyQuestion(q)
+	}
+	return &dataCopy
+}
+
+// copyQuestion returns a copy of q that shares no slices with it.
+func copyQuestion(q models.Question) *models.Question {
+	q.Voters = append([]string{}, q.Voters...)
+	q.Downvoters = slices.Clone(q.Downvoters)
+	return &q
+}
@@ -15,12 +15,16 @@ import (
 // MongoStorage implements the Storer interface for MongoDB-based storage.
 type MongoStorage struct {
 	collection *mongo.Collection
+	archive    *mongo.Collection
 }
 
-// NewMongoStorage creates a new instance of MongoStorage.
+// NewMongoStorage creates a new instance of MongoStorage. Archived sessions
+// are kept in a sibling collection named collectionName + "_archive".
 func NewMongoStorage(client *mongo.Client, dbName, collectionName string) *MongoStorage {
+	db := client.Database(dbName)
 	return &MongoStorage{
-		collection: client.Database(dbName).Collection(collectionName),
+		collection: db.Collection(collectionName),
+		archive:    db.Collection(collectionName + "_archive"),
 	}
 }
 
@@ -34,21 +38,54 @@ func (ms *MongoStorage) ConfigureIndexes(ctx context.Context) error {
 		Options: options.Index().SetUnique(true),
 	}
 
-	// TTL index on createdAt, documents expire after 24 hours
+	// TTL index on expiresAt, documents expire at the time it holds
 	ttlIndex := mongo.IndexModel{
-		Keys:    bson.M{"createdAt": 1},
-		Options: options.Index().SetExpireAfterSeconds(86400),
+		Keys:    bson.M{"expiresAt": 1},
+		Options: options.Index().SetExpireAfterSeconds(0),
 	}
 
-	_, err := ms.collection.Indexes().CreateMany(ctx, []mongo.IndexModel{sessionIdIndex, ttlIndex})
+	// Sessions used to expire through a TTL index on createdAt. Drop it and
+	// give the sessions it covered an expiresAt with the same deadline.
+	err := ms.collection.Indexes().DropOne(ctx, "createdAt_1")
+	if err != nil && !isMissingIndexError(err) {
+		return fmt.Errorf("failed to drop legacy TTL index: %w", err)
+	}
+	_, err = ms.collection.UpdateMany(ctx,
+		bson.M{"expiresAt": bson.M{"$exists": false}},
+		mongo.Pipeline{{{Key: "$set", Value: bson.M{
+			"expiresAt": bson.M{"$add": bson.A{"$createdAt", DefaultSessionTTL.Milliseconds()}},
+		}}}})
+	if err != nil {
+		return fmt.Errorf("failed to backfill session expiry: %w", err)
+	}
+
+	_, err = ms.collection.Indexes().CreateMany(ctx, []mongo.IndexModel{sessionIdIndex, ttlIndex})
 	if err != nil {
 		return fmt.Errorf("failed to create indexes: %w", err)
 	}
 
+	_, err = ms.archive.Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{Keys: bson.M{"session.sessionId": 1}, Options: options.Index().SetUnique(true)},
+		{Keys: bson.M{"retainUntil": 1}, Options: options.Index().SetExpireAfterSeconds(0)},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to create archive indexes: %w", err)
+	}
+
 	fmt.Println("MongoDB indexes configured successfully.")
 	return nil
 }
 
+// isMissingIndexError reports whether err means the index or collection to
+// drop does not exist.
+func isMissingIndexError(err error) bool {
+	var cmdErr mongo.CommandError
+	if errors.As(err, &cmdErr) {
+		return cmdErr.Code == 26 || cmdErr.Code == 27 // NamespaceNotFound, IndexNotFound
+	}
+	return false
+}
+
 // LoadSessionData retrieves a session from MongoDB.
 func (ms *MongoStorage) LoadSessionData(ctx context.Context, sessionID string) (*models.SessionData, error) {
 	var sessionData models.SessionData
@@ -64,6 +101,7 @@ func (ms *MongoStorage) LoadSessionData(ctx context.Context, sessionID string) (
 
 // CreateSessionData creates a new session document in MongoDB.
 func (ms *MongoStorage) CreateSessionData(ctx context.Context, data *models.SessionData) error {
+	setDefaultExpiry(data)
 	_, err := ms.collection.InsertOne(ctx, data)
 	if err != nil {
 		if mongo.IsDuplicateKeyError(err) {
@@ -74,15 +112,33 @@ func (ms *MongoStorage) CreateSessionData(ctx context.Context, data *models.Sess
 	return nil
 }
 
-// UpdateSessionData updates an existing session document in MongoDB.
+// UpdateSessionData replaces an existing session document in MongoDB, provided
+// its version has not changed since data was loaded.
 func (ms *MongoStorage) UpdateSessionData(ctx context.Context, data *models.SessionData) error {
-	filter := bson.M{"sessionId": data.SessionID}
-	update := bson.M{"$set": data}
+	filter := bson.M{"sessionId": data.SessionID, "version": data.Version}
+	if data.Version == 0 {
+		// Documents written before versioning was introduced have no version field.
+		filter["version"] = bson.M{"$in": bson.A{0, nil}}
+	}
+	next := *data
+	next.Version++
+	update := bson.M{"$set": &next}
 
-	_, err := ms.collection.UpdateOne(ctx, filter, update)
+	result, err := ms.collection.UpdateOne(ctx, filter, update)
 	if err != nil {
 		return fmt.Errorf("failed to update session data: %w", err)
 	}
+	if result.MatchedCount == 0 {
+		count, err := ms.collection.CountDocuments(ctx, bson.M{"sessionId": data.SessionID})
+		if err != nil {
+			return fmt.Errorf("failed to check session: %w", err)
+		}
+		if count == 0 {
+			return fmt.Errorf("session not found: %w", ErrNotFound)
+		}
+		return fmt.Errorf("session was modified concurrently: %w", ErrConflict)
+	}
+	data.Version = next.Version
 	return nil
 }
 
@@ -94,3 +150,255 @@ func (ms *MongoStorage) DeleteSessionData(ctx context.Context, sessionID string)
 	}
 	return nil
 }
+
+// ArchiveSession copies the session into the archive collection, then deletes
+// it. The archive write is an upsert, so a retry after a failed delete is
+// harmless.
+func (ms *MongoStorage) ArchiveSession(ctx context.Context, sessionID string, retainUntil time.Time) error {
+	data, err := ms.LoadSessionData(ctx, sessionID)
+	if err != nil {
+		return err
+	}
+	archived := NewArchivedSession(data, retainUntil)
+	_, err = ms.archive.ReplaceOne(ctx, bson.M{"session.sessionId": sessionID}, archived,
+		options.Replace().SetUpsert(true))
+	if err != nil {
+		return fmt.Errorf("failed to archive session: %w", err)
+	}
+	return ms.DeleteSessionData(ctx, sessionID)
+}
+
+// LoadArchivedSession retrieves an archived session from MongoDB.
+func (ms *MongoStorage) LoadArchivedSession(ctx context.Context, sessionID string) (*models.ArchivedSession, error) {
+	var archived models.ArchivedSession
+	err := ms.archive.FindOne(ctx, bson.M{"session.sessionId": sessionID}).Decode(&archived)
+	if err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return nil, fmt.Errorf("archived session not found: %w", ErrNotFound)
+		}
+		return nil, fmt.Errorf("failed to find archived session: %w", err)
+	}
+	return &archived, nil
+}
+
+// AddQuestion pushes a question onto the session document.
+func (ms *MongoStorage) AddQuestion(ctx context.Context, sessionID string, q models.Question, maxQuestions int) error {
+	return ms.AddQuestions(ctx, sessionID, []models.Question{q}, maxQuestions)
+}
+
+// AddQuestions pushes the questions onto the session document. The filter only
+// matches while the questions array has room for all of them, so the limit
+// holds under concurrent submissions.
+func (ms *MongoStorage) AddQuestions(ctx context.Context, sessionID string, qs []models.Question, maxQuestions int) error {
+	if len(qs) > maxQuestions {
+		if _, err := ms.LoadSessionData(ctx, sessionID); err != nil {
+			return err
+		}
+		return fmt.Errorf("cannot add %d questions: %w", len(qs), ErrLimitReached)
+	}
+	filter := bson.M{
+		"sessionId": sessionID,
+		fmt.Sprintf("questions.%d", maxQuestions-len(qs)): bson.M{"$exists": false},
+	}
+	update := bson.M{
+		"$push": bson.M{"questions": bson.M{"$each": qs}},
+		"$inc":  bson.M{"version": 1},
+	}
+	result, err := ms.collection.UpdateOne(ctx, filter, update)
+	if err != nil {
+		return fmt.Errorf("failed to add question: %w", err)
+	}
+	if result.MatchedCount == 0 {
+		if _, err := ms.LoadSessionData(ctx, sessionID); err != nil {
+			return err
+		}
+		return fmt.Errorf("session has %d questions: %w", maxQuestions, ErrLimitReached)
+	}
+	return nil
+}
+
+// AddVote adds voterID to the question's voters and increments its vote count.
+func (ms *MongoStorage) AddVote(ctx context.Context, sessionID, questionID, voterID string) (*models.Question, error) {
+	return ms.addVote(ctx, sessionID, questionID, voterID, "voters", 1)
+}
+
+// AddDownvote adds voterID to the question's downvoters and decrements its
+// vote count.
+func (ms *MongoStorage) AddDownvote(ctx context.Context, sessionID, questionID, voterID string) (*models.Question, error) {
+	return ms.addVote(ctx, sessionID, questionID, voterID, "downvoters", -1)
+}
+
+// addVote adds voterID to the question's list field and adds value to its vote
+// count, in a single update that only matches if voterID has not voted on the
+// question yet and has not used up the session's vote budget.
+func (ms *MongoStorage) addVote(ctx context.Context, sessionID, questionID, voterID, field string, value int) (*models.Question, error) {
+	votedOn := func(list string) bson.M {
+		return bson.M{"$in": bson.A{voterID, bson.M{"$ifNull": bson.A{"$$q." + list, bson.A{}}}}}
+	}
+	votesCast := bson.M{"$size": bson.M{"$filter": bson.M{
+		"input": "$questions",
+		"as":    "q",
+		"cond":  bson.M{"$or": bson.A{votedOn("voters"), votedOn("downvoters")}},
+	}}}
+	budget := bson.M{"$ifNull": bson.A{"$voteBudget", 0}}
+	filter := bson.M{
+		"sessionId": sessionID,
+		"questions": bson.M{"$elemMatch": bson.M{
+			"id":         questionID,
+			"voters":     bson.M{"$ne": voterID},
+			"downvoters": bson.M{"$ne": voterID},
+		}},
+		"$expr": bson.M{"$or": bson.A{
+			bson.M{"$lte": bson.A{budget, 0}},
+			bson.M{"$lt": bson.A{votesCast, budget}},
+		}},
+	}
+	update := bson.M{
+		"$addToSet": bson.M{"questions.$." + field: voterID},
+		"$inc":      bson.M{"questions.$.votes": value, "version": 1},
+	}
+	opts := options.FindOneAndUpdate().SetReturnDocument(options.After)
+
+	var data models.SessionData
+	err := ms.collection.FindOneAndUpdate(ctx, filter, update, opts).Decode(&data)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, ms.voteRejection(ctx, sessionID, questionID, voterID, false)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to add vote: %w", err)
+	}
+	q := findQuestion(&data, questionID)
+	if q == nil {
+		return nil, fmt.Errorf("question not found: %w", ErrNotFound)
+	}
+	return q, nil
+}
+
+// RemoveVote pulls voterID from the question's voters or downvoters and
+// reverts its vote count. Each attempt is a single update that only matches if
+// voterID is in that list; a voter is never in both.
+func (ms *MongoStorage) RemoveVote(ctx context.Context, sessionID, questionID, voterID string) (*models.Question, error) {
+	for field, value := range map[string]int{"voters": -1, "downvoters": 1} {
+		filter := bson.M{
+			"sessionId": sessionID,
+			"questions": bson.M{"$elemMatch": bson.M{"id": questionID, field: voterID}},
+		}
+		update := bson.M{
+			"$pull": bson.M{"questions.$." + field: voterID},
+			"$inc":  bson.M{"questions.$.votes": value, "version": 1},
+		}
+		opts := options.FindOneAndUpdate().SetReturnDocument(options.After)
+
+		var data models.SessionData
+		err := ms.collection.FindOneAndUpdate(ctx, filter, update, opts).Decode(&data)
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to remove vote: %w", err)
+		}
+		q := findQuestion(&data, questionID)
+		if q == nil {
+			return nil, fmt.Errorf("question not found: %w", ErrNotFound)
+		}
+		return q, nil
+	}
+	return nil, ms.voteRejection(ctx, sessionID, questionID, voterID, true)
+}
+
+// voteRejection explains why an addVote or RemoveVote filter did not match.
+func (ms *MongoStorage) voteRejection(ctx context.Context, sessionID, questionID, voterID string, removing bool) error {
+	data, err := ms.LoadSessionData(ctx, sessionID)
+	if err != nil {
+		return err
+	}
+	q := findQuestion(data, questionID)
+	switch {
+	case q == nil:
+		return fmt.Errorf("question not found: %w", ErrNotFound)
+	case removing:
+		return fmt.Errorf("voter %q: %w", voterID, ErrNotVoted)
+	case q.VoteOf(voterID) != 0:
+		return fmt.Errorf("voter %q: %w", voterID, ErrAlreadyVoted)
+	}
+	return fmt.Errorf("voter %q already cast %d votes: %w", voterID, data.VoteBudget, ErrNoVotesLeft)
+}
+
+// SetQuestionStatus sets the status of the matching question element.
+func (ms *MongoStorage) SetQuestionStatus(ctx context.Context, sessionID, questionID string, status models.QuestionStatus) (*models.Question, error) {
+	filter := bson.M{"sessionId": sessionID, "questions.id": questionID}
+	update := bson.M{
+		"$set": bson.M{"questions.$.status": status},
+		"$inc": bson.M{"version": 1},
+	}
+	opts := options.FindOneAndUpdate().SetReturnDocument(options.After)
+
+	var data models.SessionData
+	err := ms.collection.FindOneAndUpdate(ctx, filter, update, opts).Decode(&data)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, fmt.Errorf("question not found: %w", ErrNotFound)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to update question: %w", err)
+	}
+	q := findQuestion(&data, questionID)
+	if q == nil {
+		return nil, fmt.Errorf("question not found: %w", ErrNotFound)
+	}
+	return q, nil
+}
+
+// DeleteQuestion pulls a question from the session document.
+func (ms *MongoStorage) DeleteQuestion(ctx context.Context, sessionID, questionID string) error {
+	filter := bson.M{"sessionId": sessionID, "questions.id": questionID}
+	update := bson.M{
+		"$pull": bson.M{"questions": bson.M{"id": questionID}},
+		"$inc":  bson.M{"version": 1},
+	}
+	result, err := ms.collection.UpdateOne(ctx, filter, update)
+	if err != nil {
+		return fmt.Errorf("failed to delete question: %w", err)
+	}
+	if result.MatchedCount == 0 {
+		return fmt.Errorf("question not found: %w", ErrNotFound)
+	}
+	return nil
+}
+
+// BanSubmitter adds ip to the banned IPs and pulls all questions submitted from
+// it in one update. The removed question IDs are taken from the document as it
+// was before the update.
+func (ms *MongoStorage) BanSubmitter(ctx context.Context, sessionID, ip string) ([]string, error) {
+	// Sessions are created with a nil BannedIPs slice, stored as null, which
+	// $addToSet refuses to extend.
+	_, err := ms.collection.UpdateOne(ctx,
+		bson.M{"sessionId": sessionID, "bannedIPs": nil},
+		bson.M{"$set": bson.M{"bannedIPs": bson.A{}}})
+	if err != nil {
+		return nil, fmt.Errorf("failed to ban submitter: %w", err)
+	}
+
+	filter := bson.M{"sessionId": sessionID}
+	update := bson.M{
+		"$addToSet": bson.M{"bannedIPs": ip},
+		"$pull":     bson.M{"questions": bson.M{"submitterIP": ip}},
+		"$inc":      bson.M{"version": 1},
+	}
+	opts := options.FindOneAndUpdate().SetReturnDocument(options.Before)
+
+	var before models.SessionData
+	err = ms.collection.FindOneAndUpdate(ctx, filter, update, opts).Decode(&before)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, fmt.Errorf("session not found: %w", ErrNotFound)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to ban submitter: %w", err)
+	}
+	var removed []string
+	for _, q := range before.Questions {
+		if q.SubmitterIP == ip {
+			removed = append(removed, q.ID)
+		}
+	}
+	return removed, nil
+}
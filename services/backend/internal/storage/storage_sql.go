@@ -0,0 +1,687 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"question-voting-app/internal/models"
+)
+
+// sqlDialect captures what differs between the database/sql backends. The
+// queries in sqlStore are written with ? placeholders and rebound per dialect.
+type sqlDialect struct {
+	name string
+	// rebind rewrites ? placeholders into the driver's syntax.
+	rebind func(query string) string
+	// lockClause is appended to the SELECT that locks a session row for the
+	// rest of a transaction.
+	lockClause string
+	// tableExistsQuery counts the tables named by its single parameter.
+	tableExistsQuery string
+	// timestampType is the column type used for schema_migrations.applied_at.
+	timestampType     string
+	isUniqueViolation func(err error) bool
+	// migrationLock is run first in the transaction of a migration run, to
+	// wait for any other instance migrating the same database; empty if
+	// the transactions already exclude each other.
+	migrationLock string
+	// readTx are the options of the transactions that read a whole session,
+	// which must see a single snapshot of its rows; nil if the defaults do.
+	readTx *sql.TxOptions
+}
+
+// sqlStore implements the Storer operations shared by the SQL backends on top
+// of the sessions, questions, votes and bans tables. Every granular operation
+// locks the session row first, so concurrent writers to the same session are
+// serialised and always acquire row locks in the same order.
+type sqlStore struct {
+	db      *sql.DB
+	dialect *sqlDialect
+}
+
+func (s *sqlStore) rebind(query string) string {
+	return s.dialect.rebind(query)
+}
+
+// runCleanup periodically deletes expired sessions and archives.
+func (s *sqlStore) runCleanup() {
+	ticker := time.NewTicker(cleanupInterval)
+	defer ticker.Stop()
+	for range ticker.C {
+		if err := s.Cleanup(); err != nil {
+			fmt.Printf("%s TTL cleanup error: %v\n", s.dialect.name, err)
+		}
+	}
+}
+
+// Cleanup deletes all expired sessions, and all archives past their retention.
+func (s *sqlStore) Cleanup() error {
+	now := time.Now().UTC().Format(time.RFC3339)
+	if _, err := s.db.Exec(s.rebind(`DELETE FROM sessions WHERE expires_at < ?`), now); err != nil {
+		return err
+	}
+	_, err := s.db.Exec(s.rebind(`DELETE FROM archived_sessions WHERE retain_until < ?`), now)
+	return err
+}
+
+func (s *sqlStore) LoadSessionData(ctx context.Context, sessionID string) (*models.SessionData, error) {
+	tx, err := s.db.BeginTx(ctx, s.dialect.readTx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load session: %w", err)
+	}
+	defer tx.Rollback()
+	return s.loadSession(ctx, tx, sessionID)
+}
+
+// loadSession reads a session with its questions, votes and bans.
+func (s *sqlStore) loadSession(ctx context.Context, tx *sql.Tx, sessionID string) (*models.SessionData, error) {
+	var data models.SessionData
+	var createdAt, expiresAt string
+	err := tx.QueryRowContext(ctx, s.rebind(
+		`SELECT session_id, session_title, admin_token, is_active, created_at, expires_at, voting_mode, vote_budget, moderated, version
+		 FROM sessions WHERE session_id = ?`), sessionID).
+		Scan(&data.SessionID, &data.SessionTitle, &data.AdminToken, &data.IsActive, &createdAt, &expiresAt,
+			&data.VotingMode, &data.VoteBudget, &data.Moderated, &data.Version)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, fmt.Errorf("session not found: %w", ErrNotFound)
+		}
+		return nil, fmt.Errorf("failed to load session: %w", err)
+	}
+	if data.CreatedAt, err = time.Parse(time.RFC3339, createdAt); err != nil {
+		return nil, fmt.Errorf("failed to parse session creation time: %w", err)
+	}
+	if data.ExpiresAt, err = time.Parse(time.RFC3339, expiresAt); err != nil {
+		return nil, fmt.Errorf("failed to parse session expiry time: %w", err)
+	}
+
+	if data.Questions, err = s.loadQuestions(ctx, tx, `q.session_id = ?`, sessionID); err != nil {
+		return nil, err
+	}
+
+	rows, err := tx.QueryContext(ctx, s.rebind(`SELECT ip FROM bans WHERE session_id = ? ORDER BY ip`), sessionID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load bans: %w", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var ip string
+		if err := rows.Scan(&ip); err != nil {
+			return nil, fmt.Errorf("failed to scan ban: %w", err)
+		}
+		data.BannedIPs = append(data.BannedIPs, ip)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to load bans: %w", err)
+	}
+	return &data, nil
+}
+
+// loadQuestions returns the questions matching where, in submission order,
+// with their voters and vote counts filled in.
+func (s *sqlStore) loadQuestions(ctx context.Context, tx *sql.Tx, where string, args ...any) ([]models.Question, error) {
+	rows, err := tx.QueryContext(ctx, s.rebind(
+		`SELECT q.id, q.text, q.submitter_ip, q.created_at, q.seeded_votes, q.status FROM questions q WHERE `+where+` ORDER BY q.position`), args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load questions: %w", err)
+	}
+	questions := []models.Question{}
+	index := make(map[string]int)
+	for rows.Next() {
+		q := models.Question{Voters: []string{}}
+		var createdAt string
+		if err := rows.Scan(&q.ID, &q.Text, &q.SubmitterIP, &createdAt, &q.Votes, &q.Status); err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("failed to scan question: %w", err)
+		}
+		if q.CreatedAt, err = time.Parse(time.RFC3339, createdAt); err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("failed to parse question creation time: %w", err)
+		}
+		index[q.ID] = len(questions)
+		questions = append(questions, q)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to load questions: %w", err)
+	}
+
+	rows, err = tx.QueryContext(ctx, s.rebind(
+		`SELECT v.question_id, v.voter_id, v.value FROM votes v JOIN questions q ON q.id = v.question_id
+		 WHERE `+where+` ORDER BY v.created_at, v.voter_id`), args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load votes: %w", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var questionID, voterID string
+		var value int
+		if err := rows.Scan(&questionID, &voterID, &value); err != nil {
+			return nil, fmt.Errorf("failed to scan vote: %w", err)
+		}
+		i, ok := index[questionID]
+		if !ok {
+			continue
+		}
+		if value < 0 {
+			questions[i].Downvoters = append(questions[i].Downvoters, voterID)
+		} else {
+			questions[i].Voters = append(questions[i].Voters, voterID)
+		}
+		questions[i].Votes += value
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to load votes: %w", err)
+	}
+	return questions, nil
+}
+
+func (s *sqlStore) CreateSessionData(ctx context.Context, data *models.SessionData) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to create session: %w", err)
+	}
+	defer tx.Rollback()
+
+	setDefaultExpiry(data)
+	_, err = tx.ExecContext(ctx, s.rebind(
+		`INSERT INTO sessions (session_id, session_title, admin_token, is_active, created_at, expires_at, voting_mode, vote_budget, moderated, version)
+		 VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`),
+		data.SessionID, data.SessionTitle, data.AdminToken, data.IsActive,
+		data.CreatedAt.UTC().Format(time.RFC3339), data.ExpiresAt.UTC().Format(time.RFC3339),
+		data.VotingMode, data.VoteBudget, data.Moderated, data.Version)
+	if err != nil {
+		if s.dialect.isUniqueViolation(err) {
+			return fmt.Errorf("session already exists: %w", ErrDuplicateKey)
+		}
+		return fmt.Errorf("failed to create session: %w", err)
+	}
+	if err := s.insertChildren(ctx, tx, data); err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to create session: %w", err)
+	}
+	return nil
+}
+
+// questionTime returns the stored form of q.CreatedAt, or now if it is unset.
+func questionTime(q models.Question, now string) string {
+	if q.CreatedAt.IsZero() {
+		return now
+	}
+	return q.CreatedAt.UTC().Format(time.RFC3339)
+}
+
+// questionStatus returns the stored form of q.Status, which is open if unset.
+func questionStatus(q models.Question) models.QuestionStatus {
+	if q.Status == "" {
+		return models.QuestionOpen
+	}
+	return q.Status
+}
+
+// insertChildren writes the questions, votes and bans of data.
+func (s *sqlStore) insertChildren(ctx context.Context, tx *sql.Tx, data *models.SessionData) error {
+	now := time.Now().UTC().Format(time.RFC3339)
+	for i, q := range data.Questions {
+		if err := s.insertQuestion(ctx, tx, data.SessionID, i, q, now); err != nil {
+			return err
+		}
+	}
+	for _, ip := range data.BannedIPs {
+		_, err := tx.ExecContext(ctx, s.rebind(
+			`INSERT INTO bans (session_id, ip) VALUES (?, ?) ON CONFLICT DO NOTHING`), data.SessionID, ip)
+		if err != nil {
+			return fmt.Errorf("failed to save ban: %w", err)
+		}
+	}
+	return nil
+}
+
+// insertQuestion writes q and its votes at position. Votes beyond the net
+// score of the listed voters are stored as seeded votes.
+func (s *sqlStore) insertQuestion(ctx context.Context, tx *sql.Tx, sessionID string, position int, q models.Question, now string) error {
+	_, err := tx.ExecContext(ctx, s.rebind(
+		`INSERT INTO questions (id, session_id, position, text, submitter_ip, created_at, seeded_votes, status)
+		 VALUES (?, ?, ?, ?, ?, ?, ?, ?)`),
+		q.ID, sessionID, position, q.Text, q.SubmitterIP, questionTime(q, now),
+		max(q.Votes-len(q.Voters)+len(q.Downvoters), 0), questionStatus(q))
+	if err != nil {
+		return fmt.Errorf("failed to save question: %w", err)
+	}
+	for value, voterIDs := range map[int][]string{1: q.Voters, -1: q.Downvoters} {
+		for _, voterID := range voterIDs {
+			_, err := tx.ExecContext(ctx, s.rebind(
+				`INSERT INTO votes (question_id, voter_id, created_at, value) VALUES (?, ?, ?, ?) ON CONFLICT DO NOTHING`),
+				q.ID, voterID, now, value)
+			if err != nil {
+				return fmt.Errorf("failed to save vote: %w", err)
+			}
+		}
+	}
+	return nil
+}
+
+// UpdateSessionData replaces the session and all of its questions, votes and
+// bans, provided the stored version still matches data.Version.
+func (s *sqlStore) UpdateSessionData(ctx context.Context, data *models.SessionData) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to update session: %w", err)
+	}
+	defer tx.Rollback()
+
+	result, err := tx.ExecContext(ctx, s.rebind(
+		`UPDATE sessions SET session_title = ?, admin_token = ?, is_active = ?, expires_at = ?,
+		   voting_mode = ?, vote_budget = ?, moderated = ?, version = version + 1
+		 WHERE session_id = ? AND version = ?`),
+		data.SessionTitle, data.AdminToken, data.IsActive, data.ExpiresAt.UTC().Format(time.RFC3339),
+		data.VotingMode, data.VoteBudget, data.Moderated, data.SessionID, data.Version)
+	if err != nil {
+		return fmt.Errorf("failed to update session: %w", err)
+	}
+	n, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+	if n == 0 {
+		if err := s.lockSession(ctx, tx, data.SessionID); err != nil {
+			return err
+		}
+		return fmt.Errorf("session was modified concurrently: %w", ErrConflict)
+	}
+
+	if _, err := tx.ExecContext(ctx, s.rebind(`DELETE FROM questions WHERE session_id = ?`), data.SessionID); err != nil {
+		return fmt.Errorf("failed to update questions: %w", err)
+	}
+	if _, err := tx.ExecContext(ctx, s.rebind(`DELETE FROM bans WHERE session_id = ?`), data.SessionID); err != nil {
+		return fmt.Errorf("failed to update bans: %w", err)
+	}
+	if err := s.insertChildren(ctx, tx, data); err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to update session: %w", err)
+	}
+	data.Version++
+	return nil
+}
+
+func (s *sqlStore) DeleteSessionData(ctx context.Context, sessionID string) error {
+	_, err := s.db.ExecContext(ctx, s.rebind(`DELETE FROM sessions WHERE session_id = ?`), sessionID)
+	if err != nil {
+		return fmt.Errorf("failed to delete session: %w", err)
+	}
+	return nil
+}
+
+// AddQuestion inserts a question after the session's last one.
+func (s *sqlStore) AddQuestion(ctx context.Context, sessionID string, q models.Question, maxQuestions int) error {
+	return s.AddQuestions(ctx, sessionID, []models.Question{q}, maxQuestions)
+}
+
+// AddQuestions inserts the questions after the session's last one. The session
+// row lock keeps concurrent submissions from both passing the limit check.
+func (s *sqlStore) AddQuestions(ctx context.Context, sessionID string, qs []models.Question, maxQuestions int) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to add question: %w", err)
+	}
+	defer tx.Rollback()
+
+	if err := s.lockSession(ctx, tx, sessionID); err != nil {
+		return err
+	}
+	var count, position int
+	err = tx.QueryRowContext(ctx, s.rebind(
+		`SELECT COUNT(*), COALESCE(MAX(position) + 1, 0) FROM questions WHERE session_id = ?`), sessionID).
+		Scan(&count, &position)
+	if err != nil {
+		return fmt.Errorf("failed to count questions: %w", err)
+	}
+	if count+len(qs) > maxQuestions {
+		return fmt.Errorf("session has %d questions: %w", count, ErrLimitReached)
+	}
+	now := time.Now().UTC().Format(time.RFC3339)
+	for i, q := range qs {
+		if err := s.insertQuestion(ctx, tx, sessionID, position+i, q, now); err != nil {
+			return err
+		}
+	}
+	if err := s.bumpVersion(ctx, tx, sessionID); err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to add question: %w", err)
+	}
+	return nil
+}
+
+// AddVote inserts a vote row; the primary key rejects a second vote by the same voter.
+func (s *sqlStore) AddVote(ctx context.Context, sessionID, questionID, voterID string) (*models.Question, error) {
+	return s.addVote(ctx, sessionID, questionID, voterID, 1)
+}
+
+// AddDownvote inserts a downvote row.
+func (s *sqlStore) AddDownvote(ctx context.Context, sessionID, questionID, voterID string) (*models.Question, error) {
+	return s.addVote(ctx, sessionID, questionID, voterID, -1)
+}
+
+// addVote inserts a vote row with the given value. The session row lock keeps
+// concurrent votes by one voter from both passing the budget check.
+func (s *sqlStore) addVote(ctx context.Context, sessionID, questionID, voterID string, value int) (*models.Question, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to add vote: %w", err)
+	}
+	defer tx.Rollback()
+
+	if err := s.lockSession(ctx, tx, sessionID); err != nil {
+		return nil, err
+	}
+	if err := s.questionExists(ctx, tx, sessionID, questionID); err != nil {
+		return nil, err
+	}
+	_, err = tx.ExecContext(ctx, s.rebind(
+		`INSERT INTO votes (question_id, voter_id, created_at, value) VALUES (?, ?, ?, ?)`),
+		questionID, voterID, time.Now().UTC().Format(time.RFC3339), value)
+	if err != nil {
+		if s.dialect.isUniqueViolation(err) {
+			return nil, fmt.Errorf("voter %q: %w", voterID, ErrAlreadyVoted)
+		}
+		return nil, fmt.Errorf("failed to add vote: %w", err)
+	}
+	if err := s.checkVoteBudget(ctx, tx, sessionID, voterID); err != nil {
+		return nil, err
+	}
+	if err := s.bumpVersion(ctx, tx, sessionID); err != nil {
+		return nil, err
+	}
+	questions, err := s.loadQuestions(ctx, tx, `q.id = ?`, questionID)
+	if err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to add vote: %w", err)
+	}
+	return &questions[0], nil
+}
+
+// RemoveVote deletes the voter's vote row.
+func (s *sqlStore) RemoveVote(ctx context.Context, sessionID, questionID, voterID string) (*models.Question, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to remove vote: %w", err)
+	}
+	defer tx.Rollback()
+
+	if err := s.lockSession(ctx, tx, sessionID); err != nil {
+		return nil, err
+	}
+	if err := s.questionExists(ctx, tx, sessionID, questionID); err != nil {
+		return nil, err
+	}
+	result, err := tx.ExecContext(ctx, s.rebind(
+		`DELETE FROM votes WHERE question_id = ? AND voter_id = ?`), questionID, voterID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to remove vote: %w", err)
+	}
+	if n, err := result.RowsAffected(); err != nil {
+		return nil, fmt.Errorf("failed to remove vote: %w", err)
+	} else if n == 0 {
+		return nil, fmt.Errorf("voter %q: %w", voterID, ErrNotVoted)
+	}
+	if err := s.bumpVersion(ctx, tx, sessionID); err != nil {
+		return nil, err
+	}
+	questions, err := s.loadQuestions(ctx, tx, `q.id = ?`, questionID)
+	if err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to remove vote: %w", err)
+	}
+	return &questions[0], nil
+}
+
+// SetQuestionStatus updates the question's status column.
+func (s *sqlStore) SetQuestionStatus(ctx context.Context, sessionID, questionID string, status models.QuestionStatus) (*models.Question, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to update question: %w", err)
+	}
+	defer tx.Rollback()
+
+	if err := s.lockSession(ctx, tx, sessionID); err != nil {
+		return nil, err
+	}
+	result, err := tx.ExecContext(ctx, s.rebind(
+		`UPDATE questions SET status = ? WHERE id = ? AND session_id = ?`), status, questionID, sessionID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to update question: %w", err)
+	}
+	n, err := result.RowsAffected()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get rows affected: %w", err)
+	}
+	if n == 0 {
+		return nil, fmt.Errorf("question not found: %w", ErrNotFound)
+	}
+	if err := s.bumpVersion(ctx, tx, sessionID); err != nil {
+		return nil, err
+	}
+	questions, err := s.loadQuestions(ctx, tx, `q.id = ?`, questionID)
+	if err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to update question: %w", err)
+	}
+	return &questions[0], nil
+}
+
+// DeleteQuestion removes a question together with its votes.
+func (s *sqlStore) DeleteQuestion(ctx context.Context, sessionID, questionID string) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to delete question: %w", err)
+	}
+	defer tx.Rollback()
+
+	if err := s.lockSession(ctx, tx, sessionID); err != nil {
+		return err
+	}
+	result, err := tx.ExecContext(ctx, s.rebind(
+		`DELETE FROM questions WHERE id = ? AND session_id = ?`), questionID, sessionID)
+	if err != nil {
+		return fmt.Errorf("failed to delete question: %w", err)
+	}
+	n, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+	if n == 0 {
+		return fmt.Errorf("question not found: %w", ErrNotFound)
+	}
+	if err := s.bumpVersion(ctx, tx, sessionID); err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to delete question: %w", err)
+	}
+	return nil
+}
+
+// BanSubmitter records the ban and deletes the banned IP's questions.
+func (s *sqlStore) BanSubmitter(ctx context.Context, sessionID, ip string) ([]string, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to ban submitter: %w", err)
+	}
+	defer tx.Rollback()
+
+	if err := s.lockSession(ctx, tx, sessionID); err != nil {
+		return nil, err
+	}
+	_, err = tx.ExecContext(ctx, s.rebind(
+		`INSERT INTO bans (session_id, ip) VALUES (?, ?) ON CONFLICT DO NOTHING`), sessionID, ip)
+	if err != nil {
+		return nil, fmt.Errorf("failed to save ban: %w", err)
+	}
+
+	rows, err := tx.QueryContext(ctx, s.rebind(
+		`SELECT id FROM questions WHERE session_id = ? AND submitter_ip = ? ORDER BY position`), sessionID, ip)
+	if err != nil {
+		return nil, fmt.Errorf("failed to find banned questions: %w", err)
+	}
+	var removed []string
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("failed to scan question: %w", err)
+		}
+		removed = append(removed, id)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to find banned questions: %w", err)
+	}
+
+	_, err = tx.ExecContext(ctx, s.rebind(
+		`DELETE FROM questions WHERE session_id = ? AND submitter_ip = ?`), sessionID, ip)
+	if err != nil {
+		return nil, fmt.Errorf("failed to delete banned questions: %w", err)
+	}
+	if err := s.bumpVersion(ctx, tx, sessionID); err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to ban submitter: %w", err)
+	}
+	return removed, nil
+}
+
+// ArchiveSession stores the session as a JSON document in archived_sessions
+// and deletes it, in one transaction.
+func (s *sqlStore) ArchiveSession(ctx context.Context, sessionID string, retainUntil time.Time) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to archive session: %w", err)
+	}
+	defer tx.Rollback()
+
+	if err := s.lockSession(ctx, tx, sessionID); err != nil {
+		return err
+	}
+	data, err := s.loadSession(ctx, tx, sessionID)
+	if err != nil {
+		return err
+	}
+	archived := NewArchivedSession(data, retainUntil)
+	doc, err := json.Marshal(archived.Session)
+	if err != nil {
+		return fmt.Errorf("failed to encode archive: %w", err)
+	}
+	_, err = tx.ExecContext(ctx, s.rebind(
+		`INSERT INTO archived_sessions (session_id, data, archived_at, retain_until) VALUES (?, ?, ?, ?)
+		 ON CONFLICT (session_id) DO UPDATE SET
+		   data = excluded.data, archived_at = excluded.archived_at, retain_until = excluded.retain_until`),
+		sessionID, string(doc), archived.ArchivedAt.Format(time.RFC3339), archived.RetainUntil.Format(time.RFC3339))
+	if err != nil {
+		return fmt.Errorf("failed to archive session: %w", err)
+	}
+	if _, err := tx.ExecContext(ctx, s.rebind(`DELETE FROM sessions WHERE session_id = ?`), sessionID); err != nil {
+		return fmt.Errorf("failed to delete archived session: %w", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to archive session: %w", err)
+	}
+	return nil
+}
+
+func (s *sqlStore) LoadArchivedSession(ctx context.Context, sessionID string) (*models.ArchivedSession, error) {
+	var doc, archivedAt, retainUntil string
+	err := s.db.QueryRowContext(ctx, s.rebind(
+		`SELECT data, archived_at, retain_until FROM archived_sessions WHERE session_id = ?`), sessionID).
+		Scan(&doc, &archivedAt, &retainUntil)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, fmt.Errorf("archived session not found: %w", ErrNotFound)
+		}
+		return nil, fmt.Errorf("failed to load archived session: %w", err)
+	}
+	var archived models.ArchivedSession
+	if err := json.Unmarshal([]byte(doc), &archived.Session); err != nil {
+		return nil, fmt.Errorf("failed to decode archived session: %w", err)
+	}
+	if archived.ArchivedAt, err = time.Parse(time.RFC3339, archivedAt); err != nil {
+		return nil, fmt.Errorf("failed to parse archive time: %w", err)
+	}
+	if archived.RetainUntil, err = time.Parse(time.RFC3339, retainUntil); err != nil {
+		return nil, fmt.Errorf("failed to parse archive retention: %w", err)
+	}
+	return &archived, nil
+}
+
+// lockSession returns ErrNotFound if the session does not exist and otherwise
+// locks its row until the transaction ends (on dialects with row locks).
+func (s *sqlStore) lockSession(ctx context.Context, tx *sql.Tx, sessionID string) error {
+	var version int64
+	err := tx.QueryRowContext(ctx, s.rebind(
+		`SELECT version FROM sessions WHERE session_id = ?`+s.dialect.lockClause), sessionID).Scan(&version)
+	if err == sql.ErrNoRows {
+		return fmt.Errorf("session not found: %w", ErrNotFound)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to check session: %w", err)
+	}
+	return nil
+}
+
+func (s *sqlStore) questionExists(ctx context.Context, tx *sql.Tx, sessionID, questionID string) error {
+	var exists int
+	err := tx.QueryRowContext(ctx, s.rebind(
+		`SELECT COUNT(*) FROM questions WHERE id = ? AND session_id = ?`), questionID, sessionID).Scan(&exists)
+	if err != nil {
+		return fmt.Errorf("failed to check question: %w", err)
+	}
+	if exists == 0 {
+		return fmt.Errorf("question not found: %w", ErrNotFound)
+	}
+	return nil
+}
+
+// checkVoteBudget returns ErrNoVotesLeft if the session has a vote budget
+// and voterID has exceeded it. It runs after the new vote is inserted, so a
+// duplicate vote is reported as ErrAlreadyVoted first, and the caller rolls
+// the insert back on error.
+func (s *sqlStore) checkVoteBudget(ctx context.Context, tx *sql.Tx, sessionID, voterID string) error {
+	var budget, cast int
+	err := tx.QueryRowContext(ctx, s.rebind(
+		`SELECT s.vote_budget, COUNT(v.voter_id) FROM sessions s
+		 LEFT JOIN questions q ON q.session_id = s.session_id
+		 LEFT JOIN votes v ON v.question_id = q.id AND v.voter_id = ?
+		 WHERE s.session_id = ? GROUP BY s.vote_budget`), voterID, sessionID).Scan(&budget, &cast)
+	if err != nil {
+		return fmt.Errorf("failed to check vote budget: %w", err)
+	}
+	if budget > 0 && cast > budget {
+		return fmt.Errorf("voter %q already cast %d votes: %w", voterID, budget, ErrNoVotesLeft)
+	}
+	return nil
+}
+
+// bumpVersion increments the session version after a granular change so that
+// ETags and concurrent UpdateSessionData callers observe it.
+func (s *sqlStore) bumpVersion(ctx context.Context, tx *sql.Tx, sessionID string) error {
+	_, err := tx.ExecContext(ctx, s.rebind(`UPDATE sessions SET version = version + 1 WHERE session_id = ?`), sessionID)
+	if err != nil {
+		return fmt.Errorf("failed to update session version: %w", err)
+	}
+	return nil
+}
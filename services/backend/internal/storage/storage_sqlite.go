@@ -3,26 +3,41 @@ package storage
 import (
 	"context"
 	"database/sql"
-	"encoding/json"
 	"fmt"
 	"strings"
-	"time"
-
-	"question-voting-app/internal/models"
 
 	_ "modernc.org/sqlite"
 )
 
+var sqliteDialect = &sqlDialect{
+	name:             "SQLite",
+	rebind:           func(query string) string { return query },
+	tableExistsQuery: `SELECT COUNT(*) FROM sqlite_master WHERE type = 'table' AND name = ?`,
+	timestampType:    "DATETIME",
+	isUniqueViolation: func(err error) bool {
+		return strings.Contains(err.Error(), "UNIQUE constraint failed")
+	},
+}
+
 // SQLiteStorage implements the Storer interface using SQLite.
-// Session data is stored as a JSON blob in a single table, matching the
-// whole-document-replace update pattern used by MongoStorage.
+// Sessions, questions, votes and bans live in separate tables, so a vote is a
+// single-row insert guarded by the (question_id, voter_id) primary key.
+// SQLite has no row locks; a single connection serialises all writers instead.
 type SQLiteStorage struct {
-	db *sql.DB
+	sqlStore
 }
 
 // NewSQLiteStorage opens (or creates) a SQLite database at the given DSN.
 func NewSQLiteStorage(dsn string) (*SQLiteStorage, error) {
-	db, err := sql.Open("sqlite", dsn)
+	// Foreign keys are enforced per connection, so every connection the pool
+	// opens gets them from the DSN; the cascades depend on them. Transactions
+	// take the write lock as they begin, waiting for other processes sharing
+	// the file, such as a second instance migrating it at the same time.
+	sep := "?"
+	if strings.Contains(dsn, "?") {
+		sep = "&"
+	}
+	db, err := sql.Open("sqlite", dsn+sep+"_pragma=foreign_keys(1)&_pragma=busy_timeout(10000)&_txlock=immediate")
 	if err != nil {
 		return nil, fmt.Errorf("failed to open SQLite database: %w", err)
 	}
@@ -31,104 +46,67 @@ func NewSQLiteStorage(dsn string) (*SQLiteStorage, error) {
 	if _, err := db.Exec("PRAGMA journal_mode=WAL"); err != nil {
 		return nil, fmt.Errorf("failed to enable WAL mode: %w", err)
 	}
-	return &SQLiteStorage{db: db}, nil
+	return &SQLiteStorage{sqlStore{db: db, dialect: sqliteDialect}}, nil
 }
 
-// ConfigureIndexes creates the sessions table if it does not exist and starts
-// the background TTL cleanup goroutine.
+// ConfigureIndexes applies pending schema migrations and starts the
+// background TTL cleanup goroutine.
 func (s *SQLiteStorage) ConfigureIndexes(ctx context.Context) error {
-	_, err := s.db.ExecContext(ctx, `
-		CREATE TABLE IF NOT EXISTS sessions (
-			session_id TEXT PRIMARY KEY,
-			created_at DATETIME NOT NULL,
-			data       TEXT NOT NULL
-		)
-	`)
+	applied, err := s.Migrate(ctx)
 	if err != nil {
-		return fmt.Errorf("failed to create sessions table: %w", err)
+		return err
+	}
+	for _, m := range applied {
+		fmt.Printf("Applied SQLite migration %04d_%s.\n", m.Version, m.Name)
 	}
 	go s.runCleanup()
 	fmt.Println("SQLite storage configured successfully.")
 	return nil
 }
 
-// runCleanup periodically deletes sessions older than 24 hours.
-func (s *SQLiteStorage) runCleanup() {
-	ticker := time.NewTicker(1 * time.Hour)
-	defer ticker.Stop()
-	for range ticker.C {
-		if err := s.Cleanup(); err != nil {
-			fmt.Printf("SQLite TTL cleanup error: %v\n", err)
-		}
-	}
+// Migrate applies all pending schema migrations and returns the applied ones.
+func (s *SQLiteStorage) Migrate(ctx context.Context) ([]Migration, error) {
+	return s.migrator().up(ctx)
 }
 
-// Cleanup deletes all sessions older than 24 hours.
-func (s *SQLiteStorage) Cleanup() error {
-	cutoff := time.Now().Add(-24 * time.Hour).UTC().Format(time.RFC3339)
-	_, err := s.db.Exec(`DELETE FROM sessions WHERE created_at < ?`, cutoff)
-	return err
+// MigrationStatus lists all schema migrations and when they were applied.
+func (s *SQLiteStorage) MigrationStatus(ctx context.Context) ([]MigrationStatus, error) {
+	return s.migrator().status(ctx)
 }
 
-func (s *SQLiteStorage) LoadSessionData(ctx context.Context, sessionID string) (*models.SessionData, error) {
-	var raw string
-	err := s.db.QueryRowContext(ctx, `SELECT data FROM sessions WHERE session_id = ?`, sessionID).Scan(&raw)
-	if err != nil {
-		if err == sql.ErrNoRows {
-			return nil, fmt.Errorf("session not found: %w", ErrNotFound)
-		}
-		return nil, fmt.Errorf("failed to load session: %w", err)
-	}
-	var data models.SessionData
-	if err := json.Unmarshal([]byte(raw), &data); err != nil {
-		return nil, fmt.Errorf("failed to unmarshal session data: %w", err)
-	}
-	return &data, nil
+func (s *SQLiteStorage) migrator() *migrator {
+	return &migrator{db: s.db, dialect: sqliteDialect, dir: "sqlite", baseline: s.baselineVersion}
 }
 
-func (s *SQLiteStorage) CreateSessionData(ctx context.Context, data *models.SessionData) error {
-	raw, err := json.Marshal(data)
-	if err != nil {
-		return fmt.Errorf("failed to marshal session data: %w", err)
-	}
-	createdAt := data.CreatedAt.UTC().Format(time.RFC3339)
-	_, err = s.db.ExecContext(ctx,
-		`INSERT INTO sessions (session_id, created_at, data) VALUES (?, ?, ?)`,
-		data.SessionID, createdAt, string(raw))
-	if err != nil {
-		if strings.Contains(err.Error(), "UNIQUE constraint failed") {
-			return fmt.Errorf("session already exists: %w", ErrDuplicateKey)
+// baselineVersion infers the schema version of databases created before
+// migrations were tracked, from the tables and columns they already have.
+func (s *SQLiteStorage) baselineVersion(ctx context.Context, tx *sql.Tx) (int, error) {
+	for _, probe := range []struct {
+		version       int
+		table, column string
+	}{
+		{3, "questions", "id"},
+		{2, "sessions", "version"},
+		{1, "sessions", "session_id"},
+	} {
+		ok, err := hasColumn(ctx, tx, probe.table, probe.column)
+		if err != nil {
+			return 0, err
+		}
+		if ok {
+			return probe.version, nil
 		}
-		return fmt.Errorf("failed to create session: %w", err)
-	}
-	return nil
-}
-
-func (s *SQLiteStorage) UpdateSessionData(ctx context.Context, data *models.SessionData) error {
-	raw, err := json.Marshal(data)
-	if err != nil {
-		return fmt.Errorf("failed to marshal session data: %w", err)
-	}
-	result, err := s.db.ExecContext(ctx,
-		`UPDATE sessions SET data = ? WHERE session_id = ?`,
-		string(raw), data.SessionID)
-	if err != nil {
-		return fmt.Errorf("failed to update session: %w", err)
-	}
-	n, err := result.RowsAffected()
-	if err != nil {
-		return fmt.Errorf("failed to get rows affected: %w", err)
-	}
-	if n == 0 {
-		return fmt.Errorf("session not found: %w", ErrNotFound)
 	}
-	return nil
+	return 0, nil
 }
 
-func (s *SQLiteStorage) DeleteSessionData(ctx context.Context, sessionID string) error {
-	_, err := s.db.ExecContext(ctx, `DELETE FROM sessions WHERE session_id = ?`, sessionID)
+// hasColumn reports whether table exists and has the named column.
+func hasColumn(ctx context.Context, tx *sql.Tx, table, column string) (bool, error) {
+	var n int
+	err := tx.QueryRowContext(ctx,
+		`SELECT COUNT(*) FROM pragma_table_info(?) WHERE name = ?`, table, column).Scan(&n)
 	if err != nil {
-		return fmt.Errorf("failed to delete session: %w", err)
+		return false, fmt.Errorf("failed to inspect %s table: %w", table, err)
 	}
-	return nil
+	return n > 0, nil
 }
@@ -4,11 +4,19 @@ package storage_test
 
 import (
 	"context"
+	"database/sql"
 	"errors"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"sync"
 	"testing"
 	"time"
 
+	"github.com/testcontainers/testcontainers-go"
 	"github.com/testcontainers/testcontainers-go/modules/mongodb"
+	"github.com/testcontainers/testcontainers-go/modules/postgres"
+	"github.com/testcontainers/testcontainers-go/wait"
 	"go.mongodb.org/mongo-driver/v2/mongo"
 	"go.mongodb.org/mongo-driver/v2/mongo/options"
 
@@ -42,6 +50,188 @@ func TestMongoStorageCRUD(t *testing.T) {
 	}
 
 	testStorerCRUD(t, store)
+	testStorerGranular(t, store)
+	testStorerVotingModes(t, store)
+	testStorerArchive(t, store)
+}
+
+func TestPostgresStorageCRUD(t *testing.T) {
+	ctx := context.Background()
+
+	pgContainer, err := postgres.Run(ctx, "postgres:16-alpine",
+		postgres.WithDatabase("testdb"),
+		testcontainers.WithWaitStrategy(
+			wait.ForLog("database system is ready to accept connections").WithOccurrence(2)),
+	)
+	if err != nil {
+		t.Fatalf("failed to start postgres container: %v", err)
+	}
+	t.Cleanup(func() { pgContainer.Terminate(ctx) })
+
+	connString, err := pgContainer.ConnectionString(ctx, "sslmode=disable")
+	if err != nil {
+		t.Fatalf("failed to get connection string: %v", err)
+	}
+
+	store, err := storage.NewPostgresStorage(ctx, connString)
+	if err != nil {
+		t.Fatalf("failed to connect to postgres: %v", err)
+	}
+	if err := store.ConfigureIndexes(ctx); err != nil {
+		t.Fatalf("failed to configure indexes: %v", err)
+	}
+
+	testStorerCRUD(t, store)
+	testStorerGranular(t, store)
+	testStorerVotingModes(t, store)
+	testStorerArchive(t, store)
+
+	t.Run("cleanup", func(t *testing.T) {
+		old := &models.SessionData{SessionID: "pg-old-session", CreatedAt: time.Now().Add(-25 * time.Hour)}
+		if err := store.CreateSessionData(ctx, old); err != nil {
+			t.Fatalf("failed to create old session: %v", err)
+		}
+		if err := store.Cleanup(); err != nil {
+			t.Fatalf("cleanup failed: %v", err)
+		}
+		if _, err := store.LoadSessionData(ctx, old.SessionID); !errors.Is(err, storage.ErrNotFound) {
+			t.Errorf("expected old session to be deleted, got: %v", err)
+		}
+	})
+
+	t.Run("concurrent migrations", func(t *testing.T) {
+		db, err := sql.Open("pgx", connString)
+		if err != nil {
+			t.Fatalf("failed to open postgres: %v", err)
+		}
+		_, err = db.ExecContext(ctx, `CREATE DATABASE concurrentdb`)
+		db.Close()
+		if err != nil {
+			t.Fatalf("failed to create database: %v", err)
+		}
+		fresh := strings.Replace(connString, "/testdb?", "/concurrentdb?", 1)
+		testConcurrentMigrations(t, func() (migratable, error) {
+			return storage.NewPostgresStorage(ctx, fresh)
+		})
+	})
+
+	t.Run("migrations are recorded", func(t *testing.T) {
+		statuses, err := store.MigrationStatus(ctx)
+		if err != nil {
+			t.Fatalf("MigrationStatus failed: %v", err)
+		}
+		for _, st := range statuses {
+			if !st.Applied() {
+				t.Errorf("migration %04d_%s not applied", st.Version, st.Name)
+			}
+		}
+	})
+}
+
+func TestMemoryStorageCRUD(t *testing.T) {
+	store, err := storage.NewMemoryStorage("", 0)
+	if err != nil {
+		t.Fatalf("failed to create memory storage: %v", err)
+	}
+	testStorerCRUD(t, store)
+	testStorerGranular(t, store)
+	testStorerVotingModes(t, store)
+	testStorerArchive(t, store)
+}
+
+func TestMemoryStorageTTL(t *testing.T) {
+	ctx := context.Background()
+
+	store, err := storage.NewMemoryStorage("", 0)
+	if err != nil {
+		t.Fatalf("failed to create memory storage: %v", err)
+	}
+	old := &models.SessionData{SessionID: "old-session", CreatedAt: time.Now().Add(-25 * time.Hour)}
+	recent := &models.SessionData{SessionID: "recent-session", CreatedAt: time.Now()}
+	if err := store.CreateSessionData(ctx, old); err != nil {
+		t.Fatalf("failed to create old session: %v", err)
+	}
+	if err := store.CreateSessionData(ctx, recent); err != nil {
+		t.Fatalf("failed to create recent session: %v", err)
+	}
+
+	if _, err := store.LoadSessionData(ctx, old.SessionID); !errors.Is(err, storage.ErrNotFound) {
+		t.Errorf("expected expired session to be hidden before cleanup, got: %v", err)
+	}
+	store.Cleanup()
+	if _, err := store.LoadSessionData(ctx, recent.SessionID); err != nil {
+		t.Errorf("expected recent session to survive cleanup, got: %v", err)
+	}
+	if err := store.CreateSessionData(ctx, old); err != nil {
+		t.Errorf("expected expired session ID to be reusable after cleanup, got: %v", err)
+	}
+
+	if err := store.ArchiveSession(ctx, recent.SessionID, time.Now().Add(-time.Minute)); err != nil {
+		t.Fatalf("failed to archive recent session: %v", err)
+	}
+	if _, err := store.LoadArchivedSession(ctx, recent.SessionID); !errors.Is(err, storage.ErrNotFound) {
+		t.Errorf("expected archive past its retention to be hidden, got: %v", err)
+	}
+}
+
+func TestMemoryStorageSnapshot(t *testing.T) {
+	ctx := context.Background()
+	file := filepath.Join(t.TempDir(), "snapshot.gob")
+
+	store, err := storage.NewMemoryStorage(file, time.Minute)
+	if err != nil {
+		t.Fatalf("failed to create memory storage: %v", err)
+	}
+	session := &models.SessionData{SessionID: "snap-session", SessionTitle: "Kiosk", IsActive: true, CreatedAt: time.Now()}
+	if err := store.CreateSessionData(ctx, session); err != nil {
+		t.Fatalf("failed to create session: %v", err)
+	}
+	q := models.Question{ID: "q1", Text: "Persisted?", SubmitterIP: "1.2.3.4"}
+	if err := store.AddQuestion(ctx, session.SessionID, q, 10); err != nil {
+		t.Fatalf("failed to add question: %v", err)
+	}
+	if _, err := store.AddVote(ctx, session.SessionID, "q1", "voter"); err != nil {
+		t.Fatalf("failed to vote: %v", err)
+	}
+	if _, err := store.BanSubmitter(ctx, session.SessionID, "5.6.7.8"); err != nil {
+		t.Fatalf("failed to ban: %v", err)
+	}
+	if err := store.Snapshot(); err != nil {
+		t.Fatalf("snapshot failed: %v", err)
+	}
+
+	restored, err := storage.NewMemoryStorage(file, time.Minute)
+	if err != nil {
+		t.Fatalf("failed to restore snapshot: %v", err)
+	}
+	got, err := restored.LoadSessionData(ctx, session.SessionID)
+	if err != nil {
+		t.Fatalf("restored session missing: %v", err)
+	}
+	if got.SessionTitle != "Kiosk" || got.Version != 3 {
+		t.Errorf("restored session: got title %q version %d", got.SessionTitle, got.Version)
+	}
+	if len(got.Questions) != 1 || got.Questions[0].Votes != 1 || got.Questions[0].SubmitterIP != "1.2.3.4" {
+		t.Errorf("restored questions: %+v", got.Questions)
+	}
+	if len(got.BannedIPs) != 1 || got.BannedIPs[0] != "5.6.7.8" {
+		t.Errorf("restored bans: %v", got.BannedIPs)
+	}
+
+	if err := restored.ArchiveSession(ctx, session.SessionID, time.Now().Add(time.Hour)); err != nil {
+		t.Fatalf("failed to archive: %v", err)
+	}
+	// Closing the store writes a final snapshot.
+	if err := restored.Close(); err != nil {
+		t.Fatalf("close failed: %v", err)
+	}
+	restored, err = storage.NewMemoryStorage(file, time.Minute)
+	if err != nil {
+		t.Fatalf("failed to restore snapshot: %v", err)
+	}
+	if _, err := restored.LoadArchivedSession(ctx, session.SessionID); err != nil {
+		t.Errorf("restored archive missing: %v", err)
+	}
 }
 
 func TestSQLiteStorageCRUD(t *testing.T) {
@@ -54,6 +244,9 @@ func TestSQLiteStorageCRUD(t *testing.T) {
 	}
 
 	testStorerCRUD(t, store)
+	testStorerGranular(t, store)
+	testStorerVotingModes(t, store)
+	testStorerArchive(t, store)
 }
 
 func TestSQLiteStorageTTL(t *testing.T) {
@@ -85,6 +278,16 @@ func TestSQLiteStorageTTL(t *testing.T) {
 		t.Fatalf("failed to create recent session: %v", err)
 	}
 
+	extended := &models.SessionData{
+		SessionID: "extended-session",
+		CreatedAt: time.Now().Add(-25 * time.Hour),
+		ExpiresAt: time.Now().Add(time.Hour),
+		Questions: []models.Question{},
+	}
+	if err := store.CreateSessionData(ctx, extended); err != nil {
+		t.Fatalf("failed to create extended session: %v", err)
+	}
+
 	if err := store.Cleanup(); err != nil {
 		t.Fatalf("cleanup failed: %v", err)
 	}
@@ -95,6 +298,536 @@ func TestSQLiteStorageTTL(t *testing.T) {
 	if _, err := store.LoadSessionData(ctx, recent.SessionID); err != nil {
 		t.Errorf("expected recent session to survive cleanup, got: %v", err)
 	}
+	if _, err := store.LoadSessionData(ctx, extended.SessionID); err != nil {
+		t.Errorf("expected session with a later ExpiresAt to survive cleanup, got: %v", err)
+	}
+
+	if err := store.ArchiveSession(ctx, recent.SessionID, time.Now().Add(-time.Minute)); err != nil {
+		t.Fatalf("failed to archive recent session: %v", err)
+	}
+	if err := store.ArchiveSession(ctx, extended.SessionID, time.Now().Add(time.Hour)); err != nil {
+		t.Fatalf("failed to archive extended session: %v", err)
+	}
+	if err := store.Cleanup(); err != nil {
+		t.Fatalf("cleanup failed: %v", err)
+	}
+	if _, err := store.LoadArchivedSession(ctx, recent.SessionID); !errors.Is(err, storage.ErrNotFound) {
+		t.Errorf("expected archive past its retention to be deleted, got: %v", err)
+	}
+	if _, err := store.LoadArchivedSession(ctx, extended.SessionID); err != nil {
+		t.Errorf("expected retained archive to survive cleanup, got: %v", err)
+	}
+}
+
+func TestSQLiteStorageMigratesBlobSchema(t *testing.T) {
+	ctx := context.Background()
+	dsn := filepath.Join(t.TempDir(), "legacy.db")
+
+	legacy, err := sql.Open("sqlite", dsn)
+	if err != nil {
+		t.Fatalf("failed to open sqlite: %v", err)
+	}
+	_, err = legacy.Exec(`
+		CREATE TABLE sessions (
+			session_id TEXT PRIMARY KEY,
+			created_at DATETIME NOT NULL,
+			data       TEXT NOT NULL
+		);
+		INSERT INTO sessions VALUES ('legacy', '2030-01-02T03:04:05Z', '{
+			"sessionTitle": "Legacy", "sessionId": "legacy", "adminToken": "tok", "isActive": true,
+			"createdAt": "2030-01-02T03:04:05Z",
+			"questions": [
+				{"id": "q1", "text": "First", "votes": 2, "voters": ["a", "b"]},
+				{"id": "q2", "text": "Second", "votes": 0, "voters": []}
+			]
+		}');
+	`)
+	legacy.Close()
+	if err != nil {
+		t.Fatalf("failed to create legacy schema: %v", err)
+	}
+
+	store, err := storage.NewSQLiteStorage(dsn)
+	if err != nil {
+		t.Fatalf("failed to open sqlite: %v", err)
+	}
+	if err := store.ConfigureIndexes(ctx); err != nil {
+		t.Fatalf("failed to configure indexes: %v", err)
+	}
+
+	got, err := store.LoadSessionData(ctx, "legacy")
+	if err != nil {
+		t.Fatalf("failed to load migrated session: %v", err)
+	}
+	if got.SessionTitle != "Legacy" || got.AdminToken != "tok" || !got.IsActive {
+		t.Errorf("session fields not migrated: %+v", got)
+	}
+	if len(got.Questions) != 2 || got.Questions[0].ID != "q1" || got.Questions[1].ID != "q2" {
+		t.Fatalf("questions not migrated in order: %+v", got.Questions)
+	}
+	if got.Questions[0].Votes != 2 || len(got.Questions[0].Voters) != 2 {
+		t.Errorf("votes not migrated: %+v", got.Questions[0])
+	}
+	if want := got.CreatedAt.Add(24 * time.Hour); !got.ExpiresAt.Equal(want) {
+		t.Errorf("ExpiresAt: got %v, want %v", got.ExpiresAt, want)
+	}
+}
+
+func TestSQLiteStorageMigrationBaseline(t *testing.T) {
+	ctx := context.Background()
+	dsn := filepath.Join(t.TempDir(), "untracked.db")
+
+	// A database from before migrations were tracked, already at version 2.
+	legacy, err := sql.Open("sqlite", dsn)
+	if err != nil {
+		t.Fatalf("failed to open sqlite: %v", err)
+	}
+	_, err = legacy.Exec(`
+		CREATE TABLE sessions (
+			session_id TEXT PRIMARY KEY,
+			created_at DATETIME NOT NULL,
+			version    INTEGER NOT NULL DEFAULT 0,
+			data       TEXT NOT NULL
+		)
+	`)
+	legacy.Close()
+	if err != nil {
+		t.Fatalf("failed to create legacy schema: %v", err)
+	}
+
+	store, err := storage.NewSQLiteStorage(dsn)
+	if err != nil {
+		t.Fatalf("failed to open sqlite: %v", err)
+	}
+	statuses, err := store.MigrationStatus(ctx)
+	if err != nil {
+		t.Fatalf("failed to read migration status: %v", err)
+	}
+	for _, st := range statuses {
+		if want := st.Version <= 2; st.Applied() != want {
+			t.Errorf("migration %04d_%s: applied = %v, want %v", st.Version, st.Name, st.Applied(), want)
+		}
+	}
+
+	applied, err := store.Migrate(ctx)
+	if err != nil {
+		t.Fatalf("migrate failed: %v", err)
+	}
+	if len(applied) == 0 || applied[0].Version != 3 {
+		t.Fatalf("expected migrations from 0003 to be applied, got %+v", applied)
+	}
+	if applied, err := store.Migrate(ctx); err != nil || len(applied) != 0 {
+		t.Errorf("expected second migrate to be a no-op, got %+v, %v", applied, err)
+	}
+}
+
+func TestSQLiteStorageConcurrentMigrations(t *testing.T) {
+	dsn := filepath.Join(t.TempDir(), "shared.db")
+	testConcurrentMigrations(t, func() (migratable, error) {
+		return storage.NewSQLiteStorage(dsn)
+	})
+}
+
+type migratable interface {
+	Migrate(ctx context.Context) ([]storage.Migration, error)
+	MigrationStatus(ctx context.Context) ([]storage.MigrationStatus, error)
+}
+
+// testConcurrentMigrations migrates the same database from two storers at
+// once, as replicas starting together do; every migration must be applied
+// exactly once.
+func testConcurrentMigrations(t *testing.T, open func() (migratable, error)) {
+	t.Helper()
+	ctx := context.Background()
+
+	stores := make([]migratable, 2)
+	for i := range stores {
+		store, err := open()
+		if err != nil {
+			t.Fatalf("storer %d failed to open: %v", i, err)
+		}
+		stores[i] = store
+	}
+
+	applied := make([][]storage.Migration, len(stores))
+	errs := make([]error, len(stores))
+	var wg sync.WaitGroup
+	for i, store := range stores {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			applied[i], errs[i] = store.Migrate(ctx)
+		}()
+	}
+	wg.Wait()
+	for i, err := range errs {
+		if err != nil {
+			t.Fatalf("storer %d: migrate failed: %v", i, err)
+		}
+	}
+
+	statuses, err := stores[0].MigrationStatus(ctx)
+	if err != nil {
+		t.Fatalf("MigrationStatus failed: %v", err)
+	}
+	if n := len(applied[0]) + len(applied[1]); n != len(statuses) {
+		t.Errorf("expected %d migrations applied in total, got %d", len(statuses), n)
+	}
+	for _, st := range statuses {
+		if !st.Applied() {
+			t.Errorf("migration %04d_%s not applied", st.Version, st.Name)
+		}
+	}
+}
+
+func testStorerArchive(t *testing.T, store storage.Storer) {
+	t.Helper()
+	ctx := context.Background()
+
+	session := &models.SessionData{
+		SessionID:    "archive-session",
+		SessionTitle: "Archive Me",
+		AdminToken:   "archive-token",
+		IsActive:     true,
+		CreatedAt:    time.Now().Truncate(time.Second),
+		Questions:    []models.Question{},
+	}
+	if err := store.CreateSessionData(ctx, session); err != nil {
+		t.Fatalf("failed to create session: %v", err)
+	}
+	q := models.Question{ID: "archived-q", Text: "Kept?", Voters: []string{}, SubmitterIP: "1.2.3.4"}
+	if err := store.AddQuestion(ctx, session.SessionID, q, 10); err != nil {
+		t.Fatalf("failed to add question: %v", err)
+	}
+	if _, err := store.AddVote(ctx, session.SessionID, q.ID, "voter"); err != nil {
+		t.Fatalf("failed to vote: %v", err)
+	}
+	retainUntil := time.Now().Add(time.Hour).Truncate(time.Second)
+
+	t.Run("archive removes the live session", func(t *testing.T) {
+		if err := store.ArchiveSession(ctx, session.SessionID, retainUntil); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if _, err := store.LoadSessionData(ctx, session.SessionID); !errors.Is(err, storage.ErrNotFound) {
+			t.Errorf("expected archived session to be gone, got: %v", err)
+		}
+	})
+
+	t.Run("load archive", func(t *testing.T) {
+		got, err := store.LoadArchivedSession(ctx, session.SessionID)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if got.Session.SessionTitle != "Archive Me" || got.Session.AdminToken != "archive-token" {
+			t.Errorf("archived session: got %+v", got.Session)
+		}
+		if !got.RetainUntil.Equal(retainUntil) || got.ArchivedAt.IsZero() {
+			t.Errorf("archive times: archived %v, retain until %v", got.ArchivedAt, got.RetainUntil)
+		}
+		if len(got.Session.Questions) != 1 || got.Session.Questions[0].Votes != 1 {
+			t.Fatalf("archived questions: %+v", got.Session.Questions)
+		}
+		if got.Session.Questions[0].SubmitterIP != "" {
+			t.Errorf("archive kept submitter IP %q", got.Session.Questions[0].SubmitterIP)
+		}
+	})
+
+	t.Run("archive missing session returns ErrNotFound", func(t *testing.T) {
+		if err := store.ArchiveSession(ctx, session.SessionID, retainUntil); !errors.Is(err, storage.ErrNotFound) {
+			t.Errorf("expected ErrNotFound, got: %v", err)
+		}
+	})
+
+	t.Run("load missing archive returns ErrNotFound", func(t *testing.T) {
+		if _, err := store.LoadArchivedSession(ctx, "no-such-archive"); !errors.Is(err, storage.ErrNotFound) {
+			t.Errorf("expected ErrNotFound, got: %v", err)
+		}
+	})
+}
+
+func testStorerGranular(t *testing.T, store storage.Storer) {
+	t.Helper()
+	ctx := context.Background()
+
+	session := &models.SessionData{
+		SessionID: "granular-session",
+		IsActive:  true,
+		CreatedAt: time.Now().Truncate(time.Second),
+		Questions: []models.Question{},
+	}
+	if err := store.CreateSessionData(ctx, session); err != nil {
+		t.Fatalf("failed to create session: %v", err)
+	}
+
+	t.Run("add question", func(t *testing.T) {
+		askedAt := time.Now().UTC().Truncate(time.Second)
+		for _, id := range []string{"q1", "q2"} {
+			q := models.Question{ID: id, Text: "Question " + id, Voters: []string{}, SubmitterIP: "1.2.3.4", CreatedAt: askedAt}
+			if err := store.AddQuestion(ctx, session.SessionID, q, 2); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+		}
+		got, err := store.LoadSessionData(ctx, session.SessionID)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if len(got.Questions) != 2 || got.Questions[0].ID != "q1" || got.Questions[1].SubmitterIP != "1.2.3.4" {
+			t.Errorf("Questions not persisted correctly: %+v", got.Questions)
+		}
+		if len(got.Questions) > 0 && !got.Questions[0].CreatedAt.Equal(askedAt) {
+			t.Errorf("CreatedAt: got %v, want %v", got.Questions[0].CreatedAt, askedAt)
+		}
+		if got.Version != 2 {
+			t.Errorf("Version: got %d, want 2", got.Version)
+		}
+	})
+
+	t.Run("add question beyond limit returns ErrLimitReached", func(t *testing.T) {
+		err := store.AddQuestion(ctx, session.SessionID, models.Question{ID: "q3", Text: "One too many"}, 2)
+		if !errors.Is(err, storage.ErrLimitReached) {
+			t.Fatalf("expected ErrLimitReached, got: %v", err)
+		}
+	})
+
+	t.Run("add question to missing session returns ErrNotFound", func(t *testing.T) {
+		err := store.AddQuestion(ctx, "does-not-exist", models.Question{ID: "q4", Text: "Orphan"}, 2)
+		if !errors.Is(err, storage.ErrNotFound) {
+			t.Fatalf("expected ErrNotFound, got: %v", err)
+		}
+	})
+
+	t.Run("concurrent votes are all counted", func(t *testing.T) {
+		const voters = 20
+		var wg sync.WaitGroup
+		for i := 0; i < voters; i++ {
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+				if _, err := store.AddVote(ctx, session.SessionID, "q1", fmt.Sprintf("voter-%d", i)); err != nil {
+					t.Errorf("voter %d: unexpected error: %v", i, err)
+				}
+			}(i)
+		}
+		wg.Wait()
+
+		q, err := store.AddVote(ctx, session.SessionID, "q1", "last-voter")
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if q.Votes != voters+1 || len(q.Voters) != voters+1 {
+			t.Errorf("Votes: got %d (%d voters), want %d", q.Votes, len(q.Voters), voters+1)
+		}
+	})
+
+	t.Run("second vote returns ErrAlreadyVoted", func(t *testing.T) {
+		_, err := store.AddVote(ctx, session.SessionID, "q1", "voter-0")
+		if !errors.Is(err, storage.ErrAlreadyVoted) {
+			t.Fatalf("expected ErrAlreadyVoted, got: %v", err)
+		}
+	})
+
+	t.Run("vote on missing question returns ErrNotFound", func(t *testing.T) {
+		_, err := store.AddVote(ctx, session.SessionID, "nope", "voter-0")
+		if !errors.Is(err, storage.ErrNotFound) {
+			t.Fatalf("expected ErrNotFound, got: %v", err)
+		}
+	})
+
+	t.Run("concurrent unvotes are all counted", func(t *testing.T) {
+		const voters = 10
+		var wg sync.WaitGroup
+		for i := 0; i < voters; i++ {
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+				if _, err := store.RemoveVote(ctx, session.SessionID, "q1", fmt.Sprintf("voter-%d", i)); err != nil {
+					t.Errorf("voter %d: unexpected error: %v", i, err)
+				}
+			}(i)
+		}
+		wg.Wait()
+
+		q, err := store.RemoveVote(ctx, session.SessionID, "q1", "last-voter")
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if want := 20 - voters; q.Votes != want || len(q.Voters) != want {
+			t.Errorf("Votes: got %d (%d voters), want %d", q.Votes, len(q.Voters), want)
+		}
+	})
+
+	t.Run("unvote without a vote returns ErrNotVoted", func(t *testing.T) {
+		_, err := store.RemoveVote(ctx, session.SessionID, "q1", "voter-0")
+		if !errors.Is(err, storage.ErrNotVoted) {
+			t.Fatalf("expected ErrNotVoted, got: %v", err)
+		}
+		if _, err := store.RemoveVote(ctx, session.SessionID, "nope", "voter-0"); !errors.Is(err, storage.ErrNotFound) {
+			t.Errorf("expected ErrNotFound for a missing question, got: %v", err)
+		}
+	})
+
+	t.Run("set question status", func(t *testing.T) {
+		q, err := store.SetQuestionStatus(ctx, session.SessionID, "q2", models.QuestionPinned)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if q.ID != "q2" || q.Status != models.QuestionPinned {
+			t.Errorf("got %+v, want q2 pinned", q)
+		}
+		got, err := store.LoadSessionData(ctx, session.SessionID)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if got.Questions[1].Status != models.QuestionPinned || got.Questions[0].Status == models.QuestionPinned {
+			t.Errorf("Statuses not persisted correctly: %q, %q", got.Questions[0].Status, got.Questions[1].Status)
+		}
+		if _, err := store.SetQuestionStatus(ctx, session.SessionID, "nope", models.QuestionPinned); !errors.Is(err, storage.ErrNotFound) {
+			t.Errorf("expected ErrNotFound, got: %v", err)
+		}
+	})
+
+	t.Run("delete question", func(t *testing.T) {
+		if err := store.DeleteQuestion(ctx, session.SessionID, "q1"); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		got, err := store.LoadSessionData(ctx, session.SessionID)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if len(got.Questions) != 1 || got.Questions[0].ID != "q2" {
+			t.Errorf("Questions after delete: %+v", got.Questions)
+		}
+		if err := store.DeleteQuestion(ctx, session.SessionID, "q1"); !errors.Is(err, storage.ErrNotFound) {
+			t.Errorf("expected ErrNotFound for second delete, got: %v", err)
+		}
+	})
+
+	t.Run("ban submitter", func(t *testing.T) {
+		removed, err := store.BanSubmitter(ctx, session.SessionID, "1.2.3.4")
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if len(removed) != 1 || removed[0] != "q2" {
+			t.Errorf("removed: got %v, want [q2]", removed)
+		}
+		got, err := store.LoadSessionData(ctx, session.SessionID)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if len(got.Questions) != 0 || len(got.BannedIPs) != 1 || got.BannedIPs[0] != "1.2.3.4" {
+			t.Errorf("after ban: questions %+v, banned %v", got.Questions, got.BannedIPs)
+		}
+		if _, err := store.BanSubmitter(ctx, "does-not-exist", "1.2.3.4"); !errors.Is(err, storage.ErrNotFound) {
+			t.Errorf("expected ErrNotFound, got: %v", err)
+		}
+	})
+
+	t.Run("add questions in one batch", func(t *testing.T) {
+		batch := []models.Question{
+			{ID: "b1", Text: "Imported 1", Voters: []string{}},
+			{ID: "b2", Text: "Imported 2", Votes: 4, Voters: []string{}},
+		}
+		if err := store.AddQuestions(ctx, session.SessionID, batch, 3); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if err := store.AddQuestions(ctx, session.SessionID, batch, 3); !errors.Is(err, storage.ErrLimitReached) {
+			t.Errorf("expected ErrLimitReached for a batch beyond the limit, got: %v", err)
+		}
+		if _, err := store.AddVote(ctx, session.SessionID, "b2", "voter"); err != nil {
+			t.Fatalf("failed to vote on seeded question: %v", err)
+		}
+		got, err := store.LoadSessionData(ctx, session.SessionID)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if len(got.Questions) != 2 || got.Questions[0].ID != "b1" || got.Questions[1].Votes != 5 {
+			t.Errorf("after batch: %+v", got.Questions)
+		}
+	})
+
+	if err := store.DeleteSessionData(ctx, session.SessionID); err != nil {
+		t.Fatalf("failed to delete session: %v", err)
+	}
+}
+
+func testStorerVotingModes(t *testing.T, store storage.Storer) {
+	t.Helper()
+	ctx := context.Background()
+
+	session := &models.SessionData{
+		SessionID:  "budget-session",
+		IsActive:   true,
+		CreatedAt:  time.Now().Truncate(time.Second),
+		VotingMode: models.VotingModeBudget,
+		VoteBudget: 2,
+		Moderated:  true,
+		Questions:  []models.Question{},
+	}
+	if err := store.CreateSessionData(ctx, session); err != nil {
+		t.Fatalf("failed to create session: %v", err)
+	}
+	for _, id := range []string{"q1", "q2", "q3"} {
+		if err := store.AddQuestion(ctx, session.SessionID, models.Question{ID: id, Text: "Question " + id, Voters: []string{}}, 10); err != nil {
+			t.Fatalf("failed to add question: %v", err)
+		}
+	}
+
+	t.Run("session settings are persisted", func(t *testing.T) {
+		got, err := store.LoadSessionData(ctx, session.SessionID)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if got.VotingMode != models.VotingModeBudget || got.VoteBudget != 2 || !got.Moderated {
+			t.Errorf("got mode %q with budget %d (moderated %t), want moderated budget with 2",
+				got.VotingMode, got.VoteBudget, got.Moderated)
+		}
+	})
+
+	t.Run("downvote lowers the net score", func(t *testing.T) {
+		if _, err := store.AddVote(ctx, session.SessionID, "q1", "voter"); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		q, err := store.AddDownvote(ctx, session.SessionID, "q2", "voter")
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if q.Votes != -1 || q.VoteOf("voter") != -1 {
+			t.Errorf("got %d votes and vote %d, want -1 and -1", q.Votes, q.VoteOf("voter"))
+		}
+		if _, err := store.AddVote(ctx, session.SessionID, "q2", "voter"); !errors.Is(err, storage.ErrAlreadyVoted) {
+			t.Errorf("expected ErrAlreadyVoted after a downvote, got: %v", err)
+		}
+	})
+
+	t.Run("vote beyond budget returns ErrNoVotesLeft", func(t *testing.T) {
+		if _, err := store.AddVote(ctx, session.SessionID, "q3", "voter"); !errors.Is(err, storage.ErrNoVotesLeft) {
+			t.Fatalf("expected ErrNoVotesLeft, got: %v", err)
+		}
+		if _, err := store.AddVote(ctx, session.SessionID, "q3", "other-voter"); err != nil {
+			t.Errorf("budget should be per voter, got: %v", err)
+		}
+	})
+
+	t.Run("unvote refunds the budget", func(t *testing.T) {
+		q, err := store.RemoveVote(ctx, session.SessionID, "q2", "voter")
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if q.Votes != 0 || len(q.Downvoters) != 0 {
+			t.Errorf("got %d votes from downvoters %v, want none", q.Votes, q.Downvoters)
+		}
+		q, err = store.AddVote(ctx, session.SessionID, "q3", "voter")
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if q.Votes != 2 {
+			t.Errorf("Votes: got %d, want 2", q.Votes)
+		}
+	})
+
+	if err := store.DeleteSessionData(ctx, session.SessionID); err != nil {
+		t.Fatalf("failed to delete session: %v", err)
+	}
 }
 
 func testStorerCRUD(t *testing.T, store storage.Storer) {
@@ -135,6 +868,16 @@ func testStorerCRUD(t *testing.T, store storage.Storer) {
 		}
 	})
 
+	t.Run("create defaults ExpiresAt", func(t *testing.T) {
+		got, err := store.LoadSessionData(ctx, session.SessionID)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if want := session.CreatedAt.Add(storage.DefaultSessionTTL); !got.ExpiresAt.Equal(want) {
+			t.Errorf("ExpiresAt: got %v, want %v", got.ExpiresAt, want)
+		}
+	})
+
 	t.Run("load missing returns ErrNotFound", func(t *testing.T) {
 		_, err := store.LoadSessionData(ctx, "does-not-exist")
 		if !errors.Is(err, storage.ErrNotFound) {
@@ -144,6 +887,7 @@ func testStorerCRUD(t *testing.T, store storage.Storer) {
 
 	t.Run("update", func(t *testing.T) {
 		session.SessionTitle = "Updated Title"
+		session.ExpiresAt = session.CreatedAt.Add(72 * time.Hour)
 		session.Questions = []models.Question{
 			{ID: "q1", Text: "First question", Votes: 3, Voters: []string{"a", "b", "c"}},
 		}
@@ -157,11 +901,48 @@ func testStorerCRUD(t *testing.T, store storage.Storer) {
 		if got.SessionTitle != "Updated Title" {
 			t.Errorf("SessionTitle not persisted: got %q", got.SessionTitle)
 		}
+		if !got.ExpiresAt.Equal(session.ExpiresAt) {
+			t.Errorf("ExpiresAt not persisted: got %v, want %v", got.ExpiresAt, session.ExpiresAt)
+		}
 		if len(got.Questions) != 1 || got.Questions[0].Votes != 3 {
 			t.Errorf("Questions not persisted correctly: %+v", got.Questions)
 		}
 	})
 
+	t.Run("update increments version", func(t *testing.T) {
+		got, err := store.LoadSessionData(ctx, session.SessionID)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if got.Version != session.Version || session.Version != 1 {
+			t.Errorf("Version: got %d (stored) and %d (caller), want 1", got.Version, session.Version)
+		}
+	})
+
+	t.Run("update with stale version returns ErrConflict", func(t *testing.T) {
+		stale := *session
+		stale.Version--
+		stale.SessionTitle = "Lost Update"
+		err := store.UpdateSessionData(ctx, &stale)
+		if !errors.Is(err, storage.ErrConflict) {
+			t.Fatalf("expected ErrConflict, got: %v", err)
+		}
+		got, err := store.LoadSessionData(ctx, session.SessionID)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if got.SessionTitle != "Updated Title" {
+			t.Errorf("stale update was persisted: got %q", got.SessionTitle)
+		}
+	})
+
+	t.Run("update missing returns ErrNotFound", func(t *testing.T) {
+		err := store.UpdateSessionData(ctx, &models.SessionData{SessionID: "does-not-exist"})
+		if !errors.Is(err, storage.ErrNotFound) {
+			t.Fatalf("expected ErrNotFound, got: %v", err)
+		}
+	})
+
 	t.Run("delete", func(t *testing.T) {
 		if err := store.DeleteSessionData(ctx, session.SessionID); err != nil {
 			t.Fatalf("unexpected error: %v", err)
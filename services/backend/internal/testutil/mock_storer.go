@@ -6,18 +6,24 @@ import (
 	"fmt"
 	"question-voting-app/internal/models"
 	"question-voting-app/internal/storage"
+	"slices"
+	"sync"
+	"time"
 )
 
 // MockStorer simulates the storage layer using an in-memory map.
 // It satisfies the storage.Storer interface for unit testing.
 type MockStorer struct {
+	mu       sync.Mutex
 	sessions map[string]*models.SessionData
+	archive  map[string]*models.ArchivedSession
 }
 
 // NewMockStorer creates and returns a new in-memory Storer implementation.
 func NewMockStorer() *MockStorer {
 	return &MockStorer{
 		sessions: make(map[string]*models.SessionData),
+		archive:  make(map[string]*models.ArchivedSession),
 	}
 }
 
@@ -28,17 +34,38 @@ func (ms *MockStorer) ConfigureIndexes(ctx context.Context) error {
 
 // LoadSessionData implements the Storer interface by reading from the map.
 func (ms *MockStorer) LoadSessionData(ctx context.Context, sessionID string) (*models.SessionData, error) {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+
 	data, exists := ms.sessions[sessionID]
 	if !exists {
 		return nil, fmt.Errorf("session not found: %w", storage.ErrNotFound)
 	}
 	// Return a copy to prevent test side effects
+	return copySessionData(data), nil
+}
+
+// copySessionData deep-copies the slices of a session so that callers can
+// mutate the result without touching the stored state.
+func copySessionData(data *models.SessionData) *models.SessionData {
 	dataCopy := *data
-	return &dataCopy, nil
+	dataCopy.BannedIPs = append([]string(nil), data.BannedIPs...)
+	if data.Questions != nil {
+		dataCopy.Questions = make([]models.Question, len(data.Questions))
+		for i, q := range data.Questions {
+			q.Voters = append([]string(nil), q.Voters...)
+			q.Downvoters = append([]string(nil), q.Downvoters...)
+			dataCopy.Questions[i] = q
+		}
+	}
+	return &dataCopy
 }
 
 // CreateSessionData simulates creating a document, returning a duplicate key error if it exists.
 func (ms *MockStorer) CreateSessionData(ctx context.Context, data *models.SessionData) error {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+
 	if _, exists := ms.sessions[data.SessionID]; exists {
 		return fmt.Errorf("session already exists: %w", storage.ErrDuplicateKey)
 	}
@@ -46,17 +73,29 @@ func (ms *MockStorer) CreateSessionData(ctx context.Context, data *models.Sessio
 	return nil
 }
 
-// UpdateSessionData implements the Storer interface by writing to the map.
+// UpdateSessionData implements the Storer interface by writing to the map,
+// rejecting the write with storage.ErrConflict if the version is stale.
 func (ms *MockStorer) UpdateSessionData(ctx context.Context, data *models.SessionData) error {
-	if _, exists := ms.sessions[data.SessionID]; !exists {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+
+	current, exists := ms.sessions[data.SessionID]
+	if !exists {
 		return fmt.Errorf("session not found: %w", storage.ErrNotFound)
 	}
+	if current.Version != data.Version {
+		return fmt.Errorf("session was modified concurrently: %w", storage.ErrConflict)
+	}
+	data.Version++
 	ms.sessions[data.SessionID] = data
 	return nil
 }
 
 // DeleteSessionData implements the Storer interface by removing the entry from the map.
 func (ms *MockStorer) DeleteSessionData(ctx context.Context, sessionID string) error {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+
 	if _, exists := ms.sessions[sessionID]; !exists {
 		return fmt.Errorf("session not found: %w", storage.ErrNotFound)
 	}
@@ -64,8 +103,204 @@ func (ms *MockStorer) DeleteSessionData(ctx context.Context, sessionID string) e
 	return nil
 }
 
+// ArchiveSession implements the Storer interface by moving the session into the archive map.
+func (ms *MockStorer) ArchiveSession(ctx context.Context, sessionID string, retainUntil time.Time) error {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+
+	data, exists := ms.sessions[sessionID]
+	if !exists {
+		return fmt.Errorf("session not found: %w", storage.ErrNotFound)
+	}
+	ms.archive[sessionID] = storage.NewArchivedSession(copySessionData(data), retainUntil)
+	delete(ms.sessions, sessionID)
+	return nil
+}
+
+// LoadArchivedSession implements the Storer interface by reading from the archive map.
+func (ms *MockStorer) LoadArchivedSession(ctx context.Context, sessionID string) (*models.ArchivedSession, error) {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+
+	archived, exists := ms.archive[sessionID]
+	if !exists {
+		return nil, fmt.Errorf("archived session not found: %w", storage.ErrNotFound)
+	}
+	archivedCopy := *archived
+	archivedCopy.Session = *copySessionData(&archived.Session)
+	return &archivedCopy, nil
+}
+
+// AddQuestion implements the Storer interface by appending to the stored session.
+func (ms *MockStorer) AddQuestion(ctx context.Context, sessionID string, q models.Question, maxQuestions int) error {
+	return ms.AddQuestions(ctx, sessionID, []models.Question{q}, maxQuestions)
+}
+
+// AddQuestions implements the Storer interface by appending all questions to the stored session.
+func (ms *MockStorer) AddQuestions(ctx context.Context, sessionID string, qs []models.Question, maxQuestions int) error {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+
+	data, exists := ms.sessions[sessionID]
+	if !exists {
+		return fmt.Errorf("session not found: %w", storage.ErrNotFound)
+	}
+	if len(data.Questions)+len(qs) > maxQuestions {
+		return fmt.Errorf("session is full: %w", storage.ErrLimitReached)
+	}
+	data.Questions = append(data.Questions, qs...)
+	data.Version++
+	return nil
+}
+
+// AddVote implements the Storer interface by appending to the question's voters.
+func (ms *MockStorer) AddVote(ctx context.Context, sessionID, questionID, voterID string) (*models.Question, error) {
+	return ms.addVote(sessionID, questionID, voterID, 1)
+}
+
+// AddDownvote implements the Storer interface by appending to the question's downvoters.
+func (ms *MockStorer) AddDownvote(ctx context.Context, sessionID, questionID, voterID string) (*models.Question, error) {
+	return ms.addVote(sessionID, questionID, voterID, -1)
+}
+
+func (ms *MockStorer) addVote(sessionID, questionID, voterID string, value int) (*models.Question, error) {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+
+	data, exists := ms.sessions[sessionID]
+	if !exists {
+		return nil, fmt.Errorf("session not found: %w", storage.ErrNotFound)
+	}
+	for i, q := range data.Questions {
+		if q.ID != questionID {
+			continue
+		}
+		if q.VoteOf(voterID) != 0 {
+			return nil, fmt.Errorf("voter %q: %w", voterID, storage.ErrAlreadyVoted)
+		}
+		if data.VoteBudget > 0 && data.VotesCast(voterID) >= data.VoteBudget {
+			return nil, fmt.Errorf("voter %q: %w", voterID, storage.ErrNoVotesLeft)
+		}
+		data.Questions[i].Votes += value
+		if value < 0 {
+			data.Questions[i].Downvoters = append(data.Questions[i].Downvoters, voterID)
+		} else {
+			data.Questions[i].Voters = append(data.Questions[i].Voters, voterID)
+		}
+		data.Version++
+		voted := data.Questions[i]
+		voted.Voters = append([]string(nil), voted.Voters...)
+		voted.Downvoters = append([]string(nil), voted.Downvoters...)
+		return &voted, nil
+	}
+	return nil, fmt.Errorf("question not found: %w", storage.ErrNotFound)
+}
+
+// RemoveVote implements the Storer interface by removing the voter from the question's voters.
+func (ms *MockStorer) RemoveVote(ctx context.Context, sessionID, questionID, voterID string) (*models.Question, error) {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+
+	data, exists := ms.sessions[sessionID]
+	if !exists {
+		return nil, fmt.Errorf("session not found: %w", storage.ErrNotFound)
+	}
+	for i, q := range data.Questions {
+		if q.ID != questionID {
+			continue
+		}
+		isVoter := func(v string) bool { return v == voterID }
+		switch q.VoteOf(voterID) {
+		case 1:
+			data.Questions[i].Votes--
+			data.Questions[i].Voters = slices.DeleteFunc(slices.Clone(q.Voters), isVoter)
+		case -1:
+			data.Questions[i].Votes++
+			data.Questions[i].Downvoters = slices.DeleteFunc(slices.Clone(q.Downvoters), isVoter)
+		default:
+			return nil, fmt.Errorf("voter %q: %w", voterID, storage.ErrNotVoted)
+		}
+		data.Version++
+		unvoted := data.Questions[i]
+		unvoted.Voters = append([]string(nil), unvoted.Voters...)
+		unvoted.Downvoters = append([]string(nil), unvoted.Downvoters...)
+		return &unvoted, nil
+	}
+	return nil, fmt.Errorf("question not found: %w", storage.ErrNotFound)
+}
+
+// SetQuestionStatus implements the Storer interface by updating the question in place.
+func (ms *MockStorer) SetQuestionStatus(ctx context.Context, sessionID, questionID string, status models.QuestionStatus) (*models.Question, error) {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+
+	data, exists := ms.sessions[sessionID]
+	if !exists {
+		return nil, fmt.Errorf("session not found: %w", storage.ErrNotFound)
+	}
+	for i, q := range data.Questions {
+		if q.ID == questionID {
+			data.Questions[i].Status = status
+			data.Version++
+			updated := data.Questions[i]
+			updated.Voters = append([]string(nil), updated.Voters...)
+			updated.Downvoters = append([]string(nil), updated.Downvoters...)
+			return &updated, nil
+		}
+	}
+	return nil, fmt.Errorf("question not found: %w", storage.ErrNotFound)
+}
+
+// DeleteQuestion implements the Storer interface by removing the question from the stored session.
+func (ms *MockStorer) DeleteQuestion(ctx context.Context, sessionID, questionID string) error {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+
+	data, exists := ms.sessions[sessionID]
+	if !exists {
+		return fmt.Errorf("session not found: %w", storage.ErrNotFound)
+	}
+	for i, q := range data.Questions {
+		if q.ID == questionID {
+			data.Questions = append(data.Questions[:i:i], data.Questions[i+1:]...)
+			data.Version++
+			return nil
+		}
+	}
+	return fmt.Errorf("question not found: %w", storage.ErrNotFound)
+}
+
+// BanSubmitter implements the Storer interface by recording the ban and dropping the IP's questions.
+func (ms *MockStorer) BanSubmitter(ctx context.Context, sessionID, ip string) ([]string, error) {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+
+	data, exists := ms.sessions[sessionID]
+	if !exists {
+		return nil, fmt.Errorf("session not found: %w", storage.ErrNotFound)
+	}
+	if !slices.Contains(data.BannedIPs, ip) {
+		data.BannedIPs = append(data.BannedIPs, ip)
+	}
+	var removed []string
+	remaining := []models.Question{}
+	for _, q := range data.Questions {
+		if q.SubmitterIP == ip {
+			removed = append(removed, q.ID)
+		} else {
+			remaining = append(remaining, q)
+		}
+	}
+	data.Questions = remaining
+	data.Version++
+	return removed, nil
+}
+
 // GetSessionIDs is a test helper to inspect the mock storer's state.
 func (ms *MockStorer) GetSessionIDs() []string {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+
 	keys := make([]string, 0, len(ms.sessions))
 	for k := range ms.sessions {
 		keys = append(keys, k)
@@ -75,11 +310,18 @@ func (ms *MockStorer) GetSessionIDs() []string {
 
 // Clear is a test helper to reset the storer state between tests.
 func (ms *MockStorer) Clear() {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+
 	ms.sessions = make(map[string]*models.SessionData)
+	ms.archive = make(map[string]*models.ArchivedSession)
 }
 
 // FindSessionByAdminToken is a helper for finding a session for testing purposes.
 func (ms *MockStorer) FindSessionByAdminToken(AdminToken string) *models.SessionData {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+
 	for _, s := range ms.sessions {
 		if s.AdminToken == AdminToken {
 			// Return a copy
@@ -92,16 +334,34 @@ func (ms *MockStorer) FindSessionByAdminToken(AdminToken string) *models.Session
 
 // IsEmpty checks if the mock storer has any sessions.
 func (ms *MockStorer) IsEmpty() bool {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+
 	return len(ms.sessions) == 0
 }
 
 // HasSession checks if a session exists by its ID.
 func (ms *MockStorer) HasSession(sessionID string) bool {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+
 	_, exists := ms.sessions[sessionID]
 	return exists
 }
 
+// HasArchivedSession checks if a session has been archived.
+func (ms *MockStorer) HasArchivedSession(sessionID string) bool {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+
+	_, exists := ms.archive[sessionID]
+	return exists
+}
+
 // PreloadSession is a helper to directly add a session for test setup.
 func (ms *MockStorer) PreloadSession(data *models.SessionData) {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+
 	ms.sessions[data.SessionID] = data
 }
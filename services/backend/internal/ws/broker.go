@@ -0,0 +1,110 @@
+package ws
+
+import (
+	"context"
+	"encoding/json"
+	"sync"
+)
+
+// Broker carries the events of each session between the backend instances,
+// so that every instance can deliver them to its own clients.
+type Broker interface {
+	// Publish sends a message to the subscribers of sessionID on every
+	// instance, including this one.
+	Publish(ctx context.Context, sessionID string, message []byte) error
+	// Subscribe calls handle with the messages published to any session.
+	// The messages of a session arrive in the order they were published.
+	Subscribe(handle func(sessionID string, message []byte)) error
+	// Close stops the subscriptions and releases the connection, if any.
+	Close() error
+}
+
+// LocalBroker is a Broker for a single instance: Publish hands the message
+// straight to the subscribers, before it returns.
+type LocalBroker struct {
+	mu       sync.RWMutex
+	handlers []func(sessionID string, message []byte)
+}
+
+// NewLocalBroker returns an in-process Broker.
+func NewLocalBroker() *LocalBroker {
+	return &LocalBroker{}
+}
+
+// Publish implements Broker.
+func (b *LocalBroker) Publish(ctx context.Context, sessionID string, message []byte) error {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	for _, handle := range b.handlers {
+		handle(sessionID, message)
+	}
+	return nil
+}
+
+// Subscribe implements Broker.
+func (b *LocalBroker) Subscribe(handle func(sessionID string, message []byte)) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.handlers = append(b.handlers, handle)
+	return nil
+}
+
+// Close implements Broker.
+func (b *LocalBroker) Close() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.handlers = nil
+	return nil
+}
+
+// envelope is an event as it travels through a Broker, before it is numbered
+// by the Hub of each instance, or the presence report of a Hub.
+type envelope struct {
+	Type    string          `json:"type,omitempty"`
+	Payload json.RawMessage `json:"payload,omitempty"`
+	// Role restricts the event to the clients with this role; nil reaches
+	// all of them.
+	Role *Role `json:"role,omitempty"`
+	// Presence, if set, makes the envelope a presence report instead.
+	Presence *presenceReport `json:"presence,omitempty"`
+}
+
+// presenceReport is the number of clients a Hub has in a room. Seq increases
+// with each report of the Hub.
+type presenceReport struct {
+	Instance string `json:"instance"`
+	Seq      uint64 `json:"seq"`
+	Count    int    `json:"count"`
+}
+
+// encodeEnvelope encodes an event for the clients with role, or all clients.
+func encodeEnvelope(event Event, role *Role) ([]byte, error) {
+	env := envelope{Type: event.Type, Role: role}
+	if event.Payload != nil {
+		payload, err := json.Marshal(event.Payload)
+		if err != nil {
+			return nil, err
+		}
+		env.Payload = payload
+	}
+	return json.Marshal(env)
+}
+
+// decodeEnvelope decodes a message published by a Hub.
+func decodeEnvelope(message []byte) (envelope, error) {
+	var env envelope
+	err := json.Unmarshal(message, &env)
+	return env, err
+}
+
+// event returns the event in the envelope. Its payload is left encoded.
+func (env envelope) event() Event {
+	event := Event{Type: env.Type}
+	if len(env.Payload) > 0 {
+		event.Payload = env.Payload
+	}
+	return event
+}
@@ -0,0 +1,73 @@
+package ws
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// redisChannelPrefix namespaces the Redis channels of the sessions; the
+// channel of a session is the prefix followed by its ID.
+const redisChannelPrefix = "question-voting-app:session:"
+
+// RedisBroker is a Broker over Redis pub/sub, for running several backend
+// instances. Each instance subscribes to the channels of all sessions and
+// drops the events of sessions it has no clients for.
+type RedisBroker struct {
+	client *redis.Client
+	pubsub *redis.PubSub
+}
+
+// NewRedisBroker connects to the Redis server at url, such as
+// "redis://localhost:6379/0".
+func NewRedisBroker(ctx context.Context, url string) (*RedisBroker, error) {
+	opts, err := redis.ParseURL(url)
+	if err != nil {
+		return nil, fmt.Errorf("invalid Redis URL: %w", err)
+	}
+	client := redis.NewClient(opts)
+	if err := client.Ping(ctx).Err(); err != nil {
+		client.Close()
+		return nil, fmt.Errorf("failed to connect to Redis: %w", err)
+	}
+	return &RedisBroker{client: client}, nil
+}
+
+// Publish implements Broker.
+func (b *RedisBroker) Publish(ctx context.Context, sessionID string, message []byte) error {
+	return b.client.Publish(ctx, redisChannelPrefix+sessionID, message).Err()
+}
+
+// Subscribe implements Broker. It may only be called once. Messages published
+// while the connection to Redis is down are lost.
+func (b *RedisBroker) Subscribe(handle func(sessionID string, message []byte)) error {
+	if b.pubsub != nil {
+		return fmt.Errorf("already subscribed")
+	}
+	ctx := context.Background()
+	pubsub := b.client.PSubscribe(ctx, redisChannelPrefix+"*")
+	// Wait for the subscription, so that no message published after
+	// Subscribe returns is missed.
+	if _, err := pubsub.Receive(ctx); err != nil {
+		pubsub.Close()
+		return fmt.Errorf("failed to subscribe: %w", err)
+	}
+	b.pubsub = pubsub
+
+	go func() {
+		for msg := range pubsub.Channel() {
+			handle(strings.TrimPrefix(msg.Channel, redisChannelPrefix), []byte(msg.Payload))
+		}
+	}()
+	return nil
+}
+
+// Close implements Broker.
+func (b *RedisBroker) Close() error {
+	if b.pubsub != nil {
+		b.pubsub.Close()
+	}
+	return b.client.Close()
+}
@@ -0,0 +1,179 @@
+package ws
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+)
+
+// newRedisHub returns a Hub sharing its events through the Redis server at addr.
+func newRedisHub(t *testing.T, addr string) *Hub {
+	t.Helper()
+	broker, err := NewRedisBroker(context.Background(), "redis://"+addr)
+	if err != nil {
+		t.Fatalf("NewRedisBroker failed: %v", err)
+	}
+	t.Cleanup(func() { broker.Close() })
+	hub, err := NewHubWithBroker(false, broker)
+	if err != nil {
+		t.Fatalf("NewHubWithBroker failed: %v", err)
+	}
+	return hub
+}
+
+// nextEvent waits for the next event queued for client.
+func nextEvent(t *testing.T, client *Client) Event {
+	t.Helper()
+	select {
+	case msg := <-client.Send:
+		var event Event
+		if err := json.Unmarshal(msg, &event); err != nil {
+			t.Fatalf("invalid event %q: %v", msg, err)
+		}
+		return event
+	case <-time.After(time.Second):
+		t.Fatal("expected an event")
+		return Event{}
+	}
+}
+
+func TestRedisBroker_FansOutAcrossHubs(t *testing.T) {
+	server := miniredis.RunT(t)
+	replicaA := newRedisHub(t, server.Addr())
+	replicaB := newRedisHub(t, server.Addr())
+
+	onA := &Client{SessionID: "session1", Send: make(chan []byte, 16)}
+	onB := &Client{SessionID: "session1", Send: make(chan []byte, 16)}
+	adminOnB := &Client{SessionID: "session1", Role: RoleAdmin, Send: make(chan []byte, 16)}
+	otherOnB := &Client{SessionID: "session2", Send: make(chan []byte, 16)}
+	replicaA.Register(onA)
+	replicaB.Register(onB)
+	replicaB.Register(adminOnB)
+	replicaB.Register(otherOnB)
+
+	replicaA.Broadcast("session1", Event{Type: "VOTE_UPDATED", Payload: map[string]interface{}{"id": "q1", "votes": 3}})
+	for _, client := range []*Client{onA, onB, adminOnB} {
+		event := nextEvent(t, client)
+		payload, _ := event.Payload.(map[string]interface{})
+		if event.Type != "VOTE_UPDATED" || event.Seq == 0 || payload["votes"] != float64(3) {
+			t.Errorf("expected a numbered VOTE_UPDATED with 3 votes, got %+v", event)
+		}
+	}
+
+	replicaA.BroadcastToRole("session1", RoleAdmin, Event{Type: "QUESTION_PENDING"})
+	if event := nextEvent(t, adminOnB); event.Type != "QUESTION_PENDING" {
+		t.Errorf("expected QUESTION_PENDING for the admin, got %+v", event)
+	}
+
+	// Give any stray delivery time to arrive before checking for none.
+	time.Sleep(50 * time.Millisecond)
+	for _, client := range []*Client{onB, otherOnB} {
+		if n := len(client.Send); n != 0 {
+			t.Errorf("expected no more events for a client of session %q, got %d", client.SessionID, n)
+		}
+	}
+}
+
+func TestRedisBroker_CountsClientsAcrossHubs(t *testing.T) {
+	server := miniredis.RunT(t)
+	replicaA := newRedisHub(t, server.Addr())
+	replicaB := newRedisHub(t, server.Addr())
+	replicaA.presenceInterval = 20 * time.Millisecond
+	replicaB.presenceInterval = 20 * time.Millisecond
+
+	onA := &Client{SessionID: "session1", Send: make(chan []byte, 16)}
+	replicaA.Register(onA)
+	onB := []*Client{
+		{SessionID: "session1", Send: make(chan []byte, 16)},
+		{SessionID: "session1", Send: make(chan []byte, 16)},
+	}
+	for _, client := range onB {
+		replicaB.Register(client)
+	}
+
+	waitForCount := func(t *testing.T, hub *Hub, want int) {
+		t.Helper()
+		deadline := time.Now().Add(time.Second)
+		for hub.ClientCount("session1") != want {
+			if time.Now().After(deadline) {
+				t.Fatalf("expected %d clients across replicas, got %d", want, hub.ClientCount("session1"))
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+	}
+	waitForCount(t, replicaA, 3)
+	waitForCount(t, replicaB, 3)
+
+	// The clients of A learn of those of B once B has reported them.
+	for {
+		event := nextEvent(t, onA)
+		payload, _ := event.Payload.(map[string]interface{})
+		if event.Type != "PRESENCE" {
+			t.Fatalf("expected PRESENCE, got %+v", event)
+		}
+		if payload["count"] == float64(3) {
+			break
+		}
+	}
+
+	replicaB.Unregister(onB[0])
+	waitForCount(t, replicaA, 2)
+}
+
+func TestRedisBroker_ResumeOnAnotherHubSendsSnapshot(t *testing.T) {
+	server := miniredis.RunT(t)
+	replicaA := newRedisHub(t, server.Addr())
+	replicaB := newRedisHub(t, server.Addr())
+
+	onA := &Client{SessionID: "session1", Send: make(chan []byte, 16)}
+	onB := &Client{SessionID: "session1", Send: make(chan []byte, 16)}
+	replicaA.Register(onA)
+	replicaB.Register(onB)
+	replicaA.Broadcast("session1", Event{Type: "QUESTION_ADDED"})
+	added := func(client *Client) Event {
+		for {
+			if event := nextEvent(t, client); event.Type == "QUESTION_ADDED" {
+				return event
+			}
+		}
+	}
+	onAdded := added(onA)
+	added(onB)
+
+	// Number the events of B as A does, as may happen by chance.
+	var start, seq uint64
+	replicaA.call(func() { start, seq = replicaA.logs["session1"].start, replicaA.logs["session1"].seq })
+	replicaB.call(func() { replicaB.logs["session1"].start, replicaB.logs["session1"].seq = start, seq })
+
+	// The client of A reconnects to B.
+	resumed := &Client{SessionID: "session1", Send: make(chan []byte, 16)}
+	snapshot := func(Role) (interface{}, error) { return "state", nil }
+	if err := replicaB.attach(resumed, Cursor{Epoch: onAdded.Epoch, Seq: onAdded.Seq}, snapshot); err != nil {
+		t.Fatalf("attach failed: %v", err)
+	}
+	if event := nextEvent(t, resumed); event.Type != "SNAPSHOT" {
+		t.Errorf("expected a SNAPSHOT for a cursor of another hub, got %+v", event)
+	}
+}
+
+func TestRedisBroker_PublishFailureDeliversLocally(t *testing.T) {
+	server := miniredis.RunT(t)
+	hub := newRedisHub(t, server.Addr())
+	client := &Client{SessionID: "session1", Send: make(chan []byte, 16)}
+	hub.Register(client)
+
+	server.Close()
+	hub.Broadcast("session1", Event{Type: "QUESTION_ADDED"})
+	if event := nextEvent(t, client); event.Type != "QUESTION_ADDED" {
+		t.Errorf("expected QUESTION_ADDED, got %+v", event)
+	}
+}
+
+func TestNewRedisBroker_InvalidURL(t *testing.T) {
+	if _, err := NewRedisBroker(context.Background(), "not a url"); err == nil {
+		t.Error("expected an error for an invalid URL")
+	}
+}
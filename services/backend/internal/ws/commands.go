@@ -0,0 +1,58 @@
+package ws
+
+import (
+	"encoding/json"
+	"net/http"
+)
+
+// ackType is the type of the reply to a Command.
+const ackType = "ACK"
+
+// Command is an action a client sends over its connection, such as
+// {"type": "VOTE", "id": "r1", "questionId": "<id>", "payload": {"direction": "up"}}.
+// The ID is chosen by the client and returned in the Ack.
+type Command struct {
+	Type string `json:"type"`
+	ID   string `json:"id,omitempty"`
+	// QuestionID names the question the command acts on, if any.
+	QuestionID string `json:"questionId,omitempty"`
+	// Token is the admin token, for admin commands on a connection that is
+	// not an admin one.
+	Token   string          `json:"token,omitempty"`
+	Payload json.RawMessage `json:"payload,omitempty"`
+}
+
+// Ack is the reply to a Command. Status is an HTTP status code, as the
+// equivalent HTTP request would have returned; Payload is the response body
+// of a successful command, if any, and Error the message of a failed one.
+type Ack struct {
+	Type    string          `json:"type"`
+	ID      string          `json:"id,omitempty"`
+	OK      bool            `json:"ok"`
+	Status  int             `json:"status"`
+	Error   string          `json:"error,omitempty"`
+	Payload json.RawMessage `json:"payload,omitempty"`
+}
+
+// handleCommand runs a command message, if the client accepts commands, and
+// sends it the Ack.
+func (c *Client) handleCommand(message []byte) {
+	if c.commands == nil {
+		return
+	}
+	var cmd Command
+	var ack Ack
+	if err := json.Unmarshal(message, &cmd); err != nil || cmd.Type == "" {
+		ack = Ack{Status: http.StatusBadRequest, Error: "Invalid command"}
+	} else {
+		ack = c.commands(c.Hub.roleOf(c), cmd)
+	}
+	ack.Type = ackType
+	ack.ID = cmd.ID
+	ack.OK = ack.Status < http.StatusBadRequest
+
+	msg, err := json.Marshal(ack)
+	if err == nil {
+		c.Hub.sendTo(c, msg)
+	}
+}
@@ -0,0 +1,103 @@
+package ws
+
+import (
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Event is a message to the clients of a session.
+type Event struct {
+	// Seq orders the events of a session. The Hub assigns it when the event
+	// is broadcast, along with the Epoch of the log that numbered it, as a
+	// room gets a new log on every instance it has clients on. Transient
+	// events, such as PRESENCE, have neither and are never replayed.
+	Seq     uint64      `json:"seq,omitempty"`
+	Epoch   string      `json:"epoch,omitempty"`
+	Type    string      `json:"type"`
+	Payload interface{} `json:"payload,omitempty"`
+}
+
+// Cursor is the place of a client in the events of a room: the epoch of the
+// log and the seq of the last event it received. Clients resume from the form
+// String returns, "<epoch>:<seq>".
+type Cursor struct {
+	Epoch string
+	Seq   uint64
+}
+
+// ParseCursor parses the form of a Cursor that String returns. An invalid one
+// yields the zero Cursor, which resumes from nothing.
+func ParseCursor(s string) Cursor {
+	epoch, seq, ok := strings.Cut(s, ":")
+	n, err := strconv.ParseUint(seq, 10, 64)
+	if !ok || epoch == "" || err != nil {
+		return Cursor{}
+	}
+	return Cursor{Epoch: epoch, Seq: n}
+}
+
+func (c Cursor) String() string {
+	return c.Epoch + ":" + strconv.FormatUint(c.Seq, 10)
+}
+
+// historySize is the number of recent events a room keeps for clients that
+// reconnect. It is below the Send buffer size so a full replay always fits.
+const historySize = 128
+
+// eventLog numbers the events of a room and keeps the latest ones in a ring
+// buffer.
+type eventLog struct {
+	epoch   string                // distinguishes the log from every other
+	start   uint64                // the log holds the events after start
+	seq     uint64                // of the latest event
+	entries [historySize]logEntry // the entry of event s is at s % historySize
+}
+
+// logEntry is an encoded event and the clients it was sent to.
+type logEntry struct {
+	message []byte
+	include func(*Client) bool
+}
+
+// newEventLog returns an empty log with a new epoch, so that a cursor from an
+// earlier log of the room, or from the log of another instance, is never
+// mistaken for one of this log. Numbering starts from the clock, in
+// microseconds, so that a room that empties and later gets a new log keeps
+// increasing sequence numbers.
+func newEventLog() *eventLog {
+	start := uint64(time.Now().UnixMicro())
+	return &eventLog{epoch: uuid.NewString(), start: start, seq: start}
+}
+
+// cursor returns the cursor of the latest event.
+func (l *eventLog) cursor() Cursor {
+	return Cursor{Epoch: l.epoch, Seq: l.seq}
+}
+
+// next returns the sequence number of the next event.
+func (l *eventLog) next() uint64 {
+	return l.seq + 1
+}
+
+// add records the event numbered l.next().
+func (l *eventLog) add(message []byte, include func(*Client) bool) {
+	l.seq++
+	l.entries[l.seq%historySize] = logEntry{message: message, include: include}
+}
+
+// since returns the entries of the events after c, oldest first. It returns
+// false if some of them are no longer kept or c is not from this log.
+func (l *eventLog) since(c Cursor) ([]logEntry, bool) {
+	seq := c.Seq
+	if c.Epoch != l.epoch || seq < l.start || seq > l.seq || l.seq-seq > historySize {
+		return nil, false
+	}
+	entries := make([]logEntry, 0, l.seq-seq)
+	for s := seq + 1; s <= l.seq; s++ {
+		entries = append(entries, l.entries[s%historySize])
+	}
+	return entries, true
+}
@@ -1,38 +1,170 @@
 package ws
 
 import (
+	"context"
+	"encoding/json"
 	"log"
 	"net/http"
-	"sync"
 	"time"
 
+	"github.com/google/uuid"
 	"github.com/gorilla/websocket"
 )
 
-var (
+// Defaults for the Hub's timing.
+const (
 	writeWait  = 10 * time.Second
 	pongWait   = 60 * time.Second
 	pingPeriod = 54 * time.Second // must be less than pongWait
+
+	// presenceInterval is the shortest time between two PRESENCE events of
+	// a room.
+	presenceInterval = 2 * time.Second
+
+	// presenceRefresh is how often an instance reports the client counts of
+	// its rooms to the others, and presenceExpiry how long a report counts,
+	// so that the clients of an instance that went away are soon forgotten.
+	presenceRefresh = 15 * time.Second
+	presenceExpiry  = 3 * presenceRefresh
+)
+
+// Role decides which events a client receives.
+type Role int
+
+const (
+	// RoleParticipant receives the public event stream.
+	RoleParticipant Role = iota
+	// RoleAdmin has presented the session's admin token and also receives
+	// admin-only events.
+	RoleAdmin
 )
 
 // Client represents a single connected user.
 type Client struct {
 	Hub       *Hub
 	SessionID string
+	Role      Role // owned by the Hub's loop once registered
 	Conn      *websocket.Conn
 	Send      chan []byte
+
+	// syncing is set while the client waits for its snapshot; live events
+	// are only logged for it meanwhile. Owned by the Hub's loop.
+	syncing bool
+
+	// authenticate checks an admin token sent as the first message; nil
+	// disables authentication after the upgrade.
+	authenticate func(token string) bool
+	// snapshot loads the session state for a SNAPSHOT event; nil sends none.
+	snapshot func(role Role) (interface{}, error)
+	// commands runs the commands the client sends; nil ignores them.
+	commands func(role Role, cmd Command) Ack
+}
+
+// Messages exchanged to authenticate an open connection as the admin.
+const (
+	authRequest = "AUTH"
+	authOK      = "AUTH_OK"
+	authFailed  = "AUTH_FAILED"
+)
+
+// authMessage is the first message a participant may send to become an admin:
+// {"type": "AUTH", "payload": {"token": "<adminToken>"}}.
+type authMessage struct {
+	Type    string `json:"type"`
+	Payload struct {
+		Token string `json:"token"`
+	} `json:"payload"`
 }
 
 // Hub manages all active clients and broadcasts messages to session rooms.
+//
+// A single goroutine, run, owns the rooms and everything attached to them.
+// The exported methods hand their work to it over channels, so that
+// registering, unregistering and broadcasting never race with each other and
+// a client's Send channel is closed exactly once.
+//
+// Broadcasts go through the Hub's Broker, which brings them back to the Hubs
+// of all instances; each delivers them to its own clients. The Hubs also
+// report their client counts to each other that way, so that PRESENCE events
+// and ClientCount cover all instances.
 type Hub struct {
-	// rooms maps a sessionID to a set of active clients
-	rooms    map[string]map[*Client]bool
-	mu       sync.RWMutex
 	upgrader websocket.Upgrader
+	broker   Broker
+	// instance identifies the Hub in its presence reports.
+	instance string
+
+	// Timing of connections and PRESENCE events. Tests shorten them on a
+	// new Hub, before it serves any connection.
+	writeWait        time.Duration
+	pongWait         time.Duration
+	pingPeriod       time.Duration
+	presenceInterval time.Duration
+
+	register   chan clientRequest
+	unregister chan clientRequest
+	broadcast  chan broadcastRequest
+	// calls runs functions on the loop, for queries and other changes.
+	calls chan func()
+
+	votes voteCoalescer
+
+	// The fields below are owned by run.
+
+	// rooms maps a sessionID to a set of active clients
+	rooms map[string]map[*Client]bool
+	// logs holds the event log of each room, for as long as it has clients
+	logs map[string]*eventLog
+
+	// presenceDue holds the rooms with a PRESENCE event scheduled, and
+	// presenceSent the client count last sent to each room.
+	presenceDue  map[string]bool
+	presenceSent map[string]int
+	// presenceReported holds the client count last reported to the other
+	// instances for each room, and reportSeq numbers the reports.
+	presenceReported map[string]int
+	reportSeq        uint64
+	// peers holds the client counts reported by the other instances, by
+	// session and instance.
+	peers map[string]map[string]peerPresence
 }
 
-// NewHub creates a new WebSocket Hub.
+// peerPresence is the latest client count another instance reported for a
+// room.
+type peerPresence struct {
+	seq   uint64
+	count int
+	seen  time.Time
+}
+
+// clientRequest is a client to register or unregister. done is closed once
+// that is done.
+type clientRequest struct {
+	client *Client
+	done   chan struct{}
+}
+
+// broadcastRequest is an event to publish to the clients of a session that
+// match include. done is closed once it has been queued for them.
+type broadcastRequest struct {
+	sessionID string
+	event     Event
+	include   func(*Client) bool
+	done      chan struct{}
+}
+
+// NewHub creates a new WebSocket Hub for a single instance and starts its
+// loop, which runs for the lifetime of the process.
 func NewHub(isProduction bool) *Hub {
+	h, err := NewHubWithBroker(isProduction, NewLocalBroker())
+	if err != nil {
+		panic(err) // a LocalBroker cannot fail to subscribe
+	}
+	return h
+}
+
+// NewHubWithBroker creates a new WebSocket Hub that shares its broadcasts
+// with other instances through broker, and starts its loop.
+func NewHubWithBroker(isProduction bool, broker Broker) (*Hub, error) {
 	up := websocket.Upgrader{
 		ReadBufferSize:  1024,
 		WriteBufferSize: 1024,
@@ -44,60 +176,496 @@ func NewHub(isProduction bool) *Hub {
 		}
 	}
 
-	return &Hub{
-		rooms:    make(map[string]map[*Client]bool),
-		upgrader: up,
+	h := &Hub{
+		upgrader:         up,
+		broker:           broker,
+		instance:         uuid.New().String(),
+		writeWait:        writeWait,
+		pongWait:         pongWait,
+		pingPeriod:       pingPeriod,
+		presenceInterval: presenceInterval,
+		register:         make(chan clientRequest),
+		unregister:       make(chan clientRequest),
+		broadcast:        make(chan broadcastRequest),
+		calls:            make(chan func()),
+		rooms:            make(map[string]map[*Client]bool),
+		logs:             make(map[string]*eventLog),
+		presenceDue:      make(map[string]bool),
+		presenceSent:     make(map[string]int),
+		presenceReported: make(map[string]int),
+		peers:            make(map[string]map[string]peerPresence),
+		votes:            voteCoalescer{pending: make(map[string]map[string]int)},
+	}
+	go h.run()
+	if err := broker.Subscribe(h.receive); err != nil {
+		return nil, err
 	}
+	return h, nil
+}
+
+// run is the Hub's loop. Nothing it calls may block, or wait for the loop.
+func (h *Hub) run() {
+	refresh := time.NewTicker(presenceRefresh)
+	defer refresh.Stop()
+	for {
+		select {
+		case req := <-h.register:
+			h.add(req.client)
+			close(req.done)
+		case req := <-h.unregister:
+			h.drop(req.client)
+			close(req.done)
+			log.Printf("WS client unregistered for session %q", req.client.SessionID)
+		case req := <-h.broadcast:
+			h.publish(req.sessionID, req.event, req.include)
+			close(req.done)
+		case f := <-h.calls:
+			f()
+		case <-refresh.C:
+			h.refreshPresence()
+		}
+	}
+}
+
+// call runs f on the loop and waits for it to return.
+func (h *Hub) call(f func()) {
+	done := make(chan struct{})
+	h.calls <- func() {
+		f()
+		close(done)
+	}
+	<-done
 }
 
 // Register adds a client to a session's room.
 func (h *Hub) Register(client *Client) {
-	h.mu.Lock()
-	defer h.mu.Unlock()
+	done := make(chan struct{})
+	h.register <- clientRequest{client: client, done: done}
+	<-done
+}
 
+// add adds a client to a session's room. Runs on the loop.
+func (h *Hub) add(client *Client) {
 	if h.rooms[client.SessionID] == nil {
 		h.rooms[client.SessionID] = make(map[*Client]bool)
+		h.logs[client.SessionID] = newEventLog()
 	}
 	h.rooms[client.SessionID][client] = true
+	h.schedulePresence(client.SessionID)
 	log.Printf("WS client registered for session %q (total: %d)", client.SessionID, len(h.rooms[client.SessionID]))
 }
 
-// Unregister removes a client from a session's room.
+// Unregister removes a client from a session's room. It does nothing if the
+// client has already been removed.
 func (h *Hub) Unregister(client *Client) {
-	h.mu.Lock()
-	defer h.mu.Unlock()
-
-	if clients, ok := h.rooms[client.SessionID]; ok {
-		if _, ok := clients[client]; ok {
-			delete(clients, client)
-			close(client.Send)
-			if len(clients) == 0 {
-				delete(h.rooms, client.SessionID)
+	done := make(chan struct{})
+	h.unregister <- clientRequest{client: client, done: done}
+	<-done
+}
+
+// drop removes a client from its room, if it is still there, and closes its
+// Send channel. The room and its event log go with the last client. Runs on
+// the loop.
+func (h *Hub) drop(client *Client) {
+	clients := h.rooms[client.SessionID]
+	if !clients[client] {
+		return
+	}
+	delete(clients, client)
+	close(client.Send)
+	if len(clients) == 0 {
+		delete(h.rooms, client.SessionID)
+		delete(h.logs, client.SessionID)
+	}
+	h.schedulePresence(client.SessionID)
+}
+
+// ClientCount returns the number of clients connected to a session, on every
+// instance. The counts of the other instances lag behind by up to
+// presenceInterval.
+func (h *Hub) ClientCount(sessionID string) int {
+	var n int
+	h.call(func() { n = h.count(sessionID) })
+	return n
+}
+
+// count returns the number of clients connected to a session, adding up the
+// counts reported by the other instances. Runs on the loop.
+func (h *Hub) count(sessionID string) int {
+	n := len(h.rooms[sessionID])
+	now := time.Now()
+	for _, peer := range h.peers[sessionID] {
+		if now.Sub(peer.seen) <= presenceExpiry {
+			n += peer.count
+		}
+	}
+	return n
+}
+
+// schedulePresence arranges for a PRESENCE event to be sent to the room after
+// presenceInterval, unless one is already due, so that a burst of joins and
+// leaves results in a single event. Runs on the loop.
+func (h *Hub) schedulePresence(sessionID string) {
+	if h.presenceDue[sessionID] {
+		return
+	}
+	h.presenceDue[sessionID] = true
+	time.AfterFunc(h.presenceInterval, func() {
+		h.calls <- func() { h.sendPresence(sessionID) }
+	})
+}
+
+// sendPresence reports the room's client count to the other instances and
+// sends the room the count across all instances, each if it has changed since
+// it was last sent. Runs on the loop.
+func (h *Hub) sendPresence(sessionID string) {
+	delete(h.presenceDue, sessionID)
+	local := len(h.rooms[sessionID])
+	if h.presenceReported[sessionID] != local {
+		h.report(sessionID, local)
+	}
+	if local == 0 {
+		delete(h.presenceSent, sessionID)
+		return
+	}
+	count := h.count(sessionID)
+	if sent, ok := h.presenceSent[sessionID]; ok && sent == count {
+		return
+	}
+	h.presenceSent[sessionID] = count
+
+	msg, err := json.Marshal(Event{Type: "PRESENCE", Payload: map[string]int{"count": count}})
+	if err == nil {
+		h.deliver(sessionID, msg, everyone)
+	}
+}
+
+// report publishes the client count of a room on this instance to the other
+// instances. The broker may call back into the loop, so it is published from
+// a goroutine of its own. Runs on the loop.
+func (h *Hub) report(sessionID string, count int) {
+	if count == 0 {
+		delete(h.presenceReported, sessionID)
+	} else {
+		h.presenceReported[sessionID] = count
+	}
+	h.reportSeq++
+	message, err := json.Marshal(envelope{Presence: &presenceReport{Instance: h.instance, Seq: h.reportSeq, Count: count}})
+	if err != nil {
+		log.Printf("Failed to encode presence report: %v", err)
+		return
+	}
+	go func() {
+		if err := h.broker.Publish(context.Background(), sessionID, message); err != nil {
+			log.Printf("Failed to report presence for session %q: %v", sessionID, err)
+		}
+	}()
+}
+
+// notePresence records the client count another instance reported for a room.
+// Reports overtaken by a later one are ignored. Runs on the loop.
+func (h *Hub) notePresence(sessionID string, r presenceReport) {
+	peers := h.peers[sessionID]
+	if peers == nil {
+		peers = make(map[string]peerPresence)
+		h.peers[sessionID] = peers
+	}
+	if peer, ok := peers[r.Instance]; ok && peer.seq >= r.Seq {
+		return
+	}
+	peers[r.Instance] = peerPresence{seq: r.Seq, count: r.Count, seen: time.Now()}
+	if h.rooms[sessionID] != nil {
+		h.schedulePresence(sessionID)
+	}
+}
+
+// refreshPresence forgets the expired reports of the other instances and
+// reports the client counts of this one again, to keep them from expiring.
+// Runs on the loop.
+func (h *Hub) refreshPresence() {
+	now := time.Now()
+	for sessionID, peers := range h.peers {
+		for instance, peer := range peers {
+			if now.Sub(peer.seen) > presenceExpiry {
+				delete(peers, instance)
+				if h.rooms[sessionID] != nil {
+					h.schedulePresence(sessionID)
+				}
 			}
 		}
+		if len(peers) == 0 {
+			delete(h.peers, sessionID)
+		}
+	}
+	for sessionID, clients := range h.rooms {
+		h.report(sessionID, len(clients))
 	}
-	log.Printf("WS client unregistered for session %q", client.SessionID)
 }
 
-// Broadcast sends a message to all connected clients in a specific session.
-func (h *Hub) Broadcast(sessionID string, message []byte) {
-	h.mu.RLock()
-	defer h.mu.RUnlock()
+// everyone includes all clients of a room.
+func everyone(*Client) bool { return true }
 
-	clients := h.rooms[sessionID]
-	for client := range clients {
-		select {
-		case client.Send <- message:
-		default:
-			// If send buffer is full or blocked, assume client is disconnected
-			close(client.Send)
-			delete(clients, client)
+// Broadcast sends an event to all connected clients in a specific session, on
+// every instance. With a LocalBroker, it returns once the event has been
+// queued for them.
+func (h *Hub) Broadcast(sessionID string, event Event) {
+	h.broadcastAfterVotes(sessionID, event, nil)
+}
+
+// BroadcastToRole sends an event to the session's clients with the given role.
+func (h *Hub) BroadcastToRole(sessionID string, role Role, event Event) {
+	h.broadcastAfterVotes(sessionID, event, &role)
+}
+
+// BroadcastLocal sends an event only to the session's clients on this
+// instance, for events that every instance raises for its own clients.
+func (h *Hub) BroadcastLocal(sessionID string, event Event) {
+	h.send(sessionID, event, everyone)
+}
+
+// fanOut publishes an event for the session's clients with role, or all of
+// them if role is nil, through the broker. If that fails, the event at least
+// reaches the clients of this instance.
+func (h *Hub) fanOut(sessionID string, event Event, role *Role) {
+	message, err := encodeEnvelope(event, role)
+	if err != nil {
+		log.Printf("Failed to encode %s event: %v", event.Type, err)
+		return
+	}
+	if err := h.broker.Publish(context.Background(), sessionID, message); err != nil {
+		log.Printf("Failed to publish %s event for session %q, delivering it locally: %v", event.Type, sessionID, err)
+		h.receive(sessionID, message)
+	}
+}
+
+// receive delivers an event published through the broker to the clients of
+// this instance, or records the presence report of another instance.
+func (h *Hub) receive(sessionID string, message []byte) {
+	env, err := decodeEnvelope(message)
+	if err != nil {
+		log.Printf("Dropping invalid event for session %q: %v", sessionID, err)
+		return
+	}
+	if report := env.Presence; report != nil {
+		if report.Instance != h.instance {
+			h.call(func() { h.notePresence(sessionID, *report) })
+		}
+		return
+	}
+	include := everyone
+	if env.Role != nil {
+		role := *env.Role
+		include = func(c *Client) bool { return c.Role == role }
+	}
+	h.send(sessionID, env.event(), include)
+}
+
+// send hands an event to the loop for publishing and waits until it is done.
+func (h *Hub) send(sessionID string, event Event, include func(*Client) bool) {
+	done := make(chan struct{})
+	h.broadcast <- broadcastRequest{sessionID: sessionID, event: event, include: include, done: done}
+	<-done
+}
+
+// publish numbers an event, records it in the room's log and sends it to the
+// clients that match include. Events for rooms without clients are dropped.
+// Runs on the loop.
+func (h *Hub) publish(sessionID string, event Event, include func(*Client) bool) {
+	events := h.logs[sessionID]
+	if events == nil {
+		return
+	}
+	event.Seq, event.Epoch = events.next(), events.epoch
+	message, err := json.Marshal(event)
+	if err != nil {
+		log.Printf("Failed to encode %s event: %v", event.Type, err)
+		return
+	}
+	events.add(message, include)
+	h.deliver(sessionID, message, include)
+}
+
+// deliver sends a message to the clients in a session that match include.
+// Clients that are still syncing get it later from the log. Runs on the loop.
+func (h *Hub) deliver(sessionID string, message []byte, include func(*Client) bool) {
+	for client := range h.rooms[sessionID] {
+		if client.syncing || !include(client) {
+			continue
+		}
+		h.queue(client, message)
+	}
+}
+
+// queue queues a message for a client. A client whose send buffer is full
+// cannot keep up, or is gone: it is evicted, and its writePump closes the
+// connection once it has drained the buffer. Runs on the loop.
+func (h *Hub) queue(client *Client, message []byte) {
+	select {
+	case client.Send <- message:
+	default:
+		log.Printf("WS client of session %q is too slow, evicting it", client.SessionID)
+		h.drop(client)
+	}
+}
+
+// sendTo sends a message to one client, unless it has been dropped from its room.
+func (h *Hub) sendTo(client *Client, message []byte) {
+	h.call(func() {
+		if h.rooms[client.SessionID][client] {
+			h.queue(client, message)
+		}
+	})
+}
+
+// attach registers a client and brings it up to date: with the events after
+// last if the room's log still holds them, or else with a SNAPSHOT event
+// carrying the result of snapshot for the client's role, followed by any
+// events logged while the snapshot was taken. Those events may already be
+// part of the snapshot, so clients must apply them idempotently. A cursor
+// from another log of the room, such as that of another instance, always gets
+// a SNAPSHOT. Without last or snapshot the client only receives new events.
+// The client is dropped if the snapshot fails.
+func (h *Hub) attach(client *Client, last Cursor, snapshot func(role Role) (interface{}, error)) error {
+	var at Cursor
+	var role Role
+	caughtUp := false
+	h.call(func() {
+		h.add(client)
+		if (last.Seq != 0 && h.replay(client, last)) || snapshot == nil {
+			caughtUp = true
+			return
+		}
+		at, role = h.startSync(client)
+	})
+	if caughtUp {
+		return nil
+	}
+	return h.sync(client, at, role, snapshot)
+}
+
+// resync sends a registered client a new SNAPSHOT event, as attach does, for
+// its current role. The client is dropped if the snapshot fails.
+func (h *Hub) resync(client *Client, snapshot func(role Role) (interface{}, error)) error {
+	var at Cursor
+	var role Role
+	registered := false
+	h.call(func() {
+		if registered = h.rooms[client.SessionID][client]; registered {
+			at, role = h.startSync(client)
+		}
+	})
+	if !registered {
+		return nil
+	}
+	return h.sync(client, at, role, snapshot)
+}
+
+// startSync holds back the live events for a client about to be sent a
+// snapshot, and returns the cursor the snapshot is current to and the role it
+// is for. Runs on the loop.
+func (h *Hub) startSync(client *Client) (Cursor, Role) {
+	client.syncing = true
+	return h.logs[client.SessionID].cursor(), client.Role
+}
+
+// sync sends a client started on with startSync the SNAPSHOT for role, then
+// the events logged after at.
+func (h *Hub) sync(client *Client, at Cursor, role Role, snapshot func(role Role) (interface{}, error)) error {
+	// Load the snapshot off the loop; events published meanwhile are
+	// logged and replayed after it, so none are lost, though some may be
+	// delivered twice.
+	payload, err := snapshot(role)
+	var message []byte
+	if err == nil {
+		message, err = json.Marshal(Event{Seq: at.Seq, Epoch: at.Epoch, Type: "SNAPSHOT", Payload: payload})
+	}
+
+	h.call(func() {
+		client.syncing = false
+		if !h.rooms[client.SessionID][client] {
+			return
+		}
+		if err != nil {
+			h.drop(client)
+			return
 		}
+		h.queue(client, message)
+		if !h.replay(client, at) {
+			// Too many events to catch up on; the client reconnects.
+			h.drop(client)
+		}
+	})
+	return err
+}
+
+// replay sends a client the logged events after c that were meant for it.
+// It returns false if the log no longer holds all of them. Runs on the loop.
+func (h *Hub) replay(client *Client, c Cursor) bool {
+	events := h.logs[client.SessionID]
+	if events == nil {
+		return false
+	}
+	entries, ok := events.since(c)
+	if !ok {
+		return false
 	}
+	for _, e := range entries {
+		if e.include(client) {
+			h.queue(client, e.message)
+		}
+	}
+	return true
 }
 
-// ServeWS upgrades the HTTP connection and registers the client.
-func (h *Hub) ServeWS(w http.ResponseWriter, r *http.Request, sessionID string) {
+// setRole changes the role of a registered client.
+func (h *Hub) setRole(client *Client, role Role) {
+	h.call(func() { client.Role = role })
+}
+
+// roleOf returns the current role of a client.
+func (h *Hub) roleOf(client *Client) Role {
+	var role Role
+	h.call(func() { role = client.Role })
+	return role
+}
+
+// SessionIDs returns the sessions that currently have connected clients.
+func (h *Hub) SessionIDs() []string {
+	var ids []string
+	h.call(func() {
+		ids = make([]string, 0, len(h.rooms))
+		for id := range h.rooms {
+			ids = append(ids, id)
+		}
+	})
+	return ids
+}
+
+// Connection describes a WebSocket client to serve.
+type Connection struct {
+	SessionID string
+	// Role is the client's role as established from the upgrade request.
+	Role Role
+	// Authenticate checks the token of an AUTH message; a participant whose
+	// token it accepts becomes an admin. Nil rejects every AUTH message.
+	Authenticate func(token string) bool
+	// LastEvent is the cursor of the last event the client received before
+	// it reconnected, or the zero Cursor.
+	LastEvent Cursor
+	// Snapshot loads the session state for role sent in a SNAPSHOT event when
+	// the client cannot be caught up from LastEvent, and again once an AUTH
+	// message has made it an admin. Nil sends no snapshot.
+	Snapshot func(role Role) (interface{}, error)
+	// Commands runs a command sent by the client, with the client's current
+	// role, and returns its Ack. Nil ignores commands.
+	Commands func(role Role, cmd Command) Ack
+}
+
+// ServeWS upgrades the HTTP connection, registers the client described by c
+// and brings it up to date. A participant may still become an admin by
+// sending an AUTH message first. Any other message is a Command, which
+// c.Commands runs and answers with an ACK.
+func (h *Hub) ServeWS(w http.ResponseWriter, r *http.Request, c Connection) {
 	conn, err := h.upgrader.Upgrade(w, r, nil)
 	if err != nil {
 		log.Println("WebSocket upgrade error:", err)
@@ -105,16 +673,21 @@ func (h *Hub) ServeWS(w http.ResponseWriter, r *http.Request, sessionID string)
 	}
 
 	client := &Client{
-		Hub:       h,
-		SessionID: sessionID,
-		Conn:      conn,
-		Send:      make(chan []byte, 256),
+		Hub:          h,
+		SessionID:    c.SessionID,
+		Role:         c.Role,
+		Conn:         conn,
+		Send:         make(chan []byte, 256),
+		authenticate: c.Authenticate,
+		snapshot:     c.Snapshot,
+		commands:     c.Commands,
 	}
 
-	h.Register(client)
-
-	// Start pump goroutines
 	go client.writePump()
+	if err := h.attach(client, c.LastEvent, c.Snapshot); err != nil {
+		log.Printf("Failed to load snapshot for session %q: %v", c.SessionID, err)
+		return
+	}
 	go client.readPump()
 }
 
@@ -124,25 +697,52 @@ func (c *Client) readPump() {
 		c.Hub.Unregister(c)
 		c.Conn.Close()
 	}()
-	c.Conn.SetReadDeadline(time.Now().Add(pongWait))
+	c.Conn.SetReadDeadline(time.Now().Add(c.Hub.pongWait))
 	c.Conn.SetPongHandler(func(string) error {
-		c.Conn.SetReadDeadline(time.Now().Add(pongWait))
+		c.Conn.SetReadDeadline(time.Now().Add(c.Hub.pongWait))
 		return nil
 	})
-	for {
-		_, _, err := c.Conn.ReadMessage()
+	for first := true; ; first = false {
+		_, message, err := c.Conn.ReadMessage()
 		if err != nil {
 			if websocket.IsUnexpectedCloseError(err, websocket.CloseGoingAway, websocket.CloseAbnormalClosure) {
 				log.Printf("WebSocket read error: %v", err)
 			}
 			break
 		}
+		if first && c.handleAuth(message) {
+			continue
+		}
+		c.handleCommand(message)
+	}
+}
+
+// handleAuth promotes the client to admin if message is an AUTH message with
+// a valid admin token, and acknowledges the outcome. An admin is then sent the
+// admin SNAPSHOT, as if it had connected with the token. It returns false if
+// message is not an AUTH message.
+func (c *Client) handleAuth(message []byte) bool {
+	var msg authMessage
+	if err := json.Unmarshal(message, &msg); err != nil || msg.Type != authRequest {
+		return false
+	}
+	if c.authenticate == nil || !c.authenticate(msg.Payload.Token) {
+		c.Hub.sendTo(c, []byte(`{"type":"`+authFailed+`"}`))
+		return true
+	}
+	c.Hub.setRole(c, RoleAdmin)
+	c.Hub.sendTo(c, []byte(`{"type":"`+authOK+`"}`))
+	if c.snapshot != nil {
+		if err := c.Hub.resync(c, c.snapshot); err != nil {
+			log.Printf("Failed to load snapshot for session %q: %v", c.SessionID, err)
+		}
 	}
+	return true
 }
 
 // writePump sends messages from the hub to the client.
 func (c *Client) writePump() {
-	ticker := time.NewTicker(pingPeriod)
+	ticker := time.NewTicker(c.Hub.pingPeriod)
 	defer func() {
 		ticker.Stop()
 		c.Conn.Close()
@@ -150,7 +750,7 @@ func (c *Client) writePump() {
 	for {
 		select {
 		case message, ok := <-c.Send:
-			c.Conn.SetWriteDeadline(time.Now().Add(writeWait))
+			c.Conn.SetWriteDeadline(time.Now().Add(c.Hub.writeWait))
 			if !ok {
 				c.Conn.WriteMessage(websocket.CloseMessage, []byte{})
 				return
@@ -164,7 +764,7 @@ func (c *Client) writePump() {
 				return
 			}
 		case <-ticker.C:
-			c.Conn.SetWriteDeadline(time.Now().Add(writeWait))
+			c.Conn.SetWriteDeadline(time.Now().Add(c.Hub.writeWait))
 			if err := c.Conn.WriteMessage(websocket.PingMessage, nil); err != nil {
 				return
 			}
@@ -1,9 +1,13 @@
 package ws
 
 import (
+	"encoding/json"
 	"net/http"
 	"net/http/httptest"
+	"net/url"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 
@@ -19,15 +23,14 @@ func TestHub_Register(t *testing.T) {
 
 	hub.Register(client)
 
-	hub.mu.RLock()
-	defer hub.mu.RUnlock()
-
-	if _, ok := hub.rooms["session1"]; !ok {
-		t.Fatal("expected room 'session1' to exist")
-	}
-	if !hub.rooms["session1"][client] {
-		t.Fatal("expected client to be registered in 'session1'")
-	}
+	hub.call(func() {
+		if _, ok := hub.rooms["session1"]; !ok {
+			t.Error("expected room 'session1' to exist")
+		}
+		if !hub.rooms["session1"][client] {
+			t.Error("expected client to be registered in 'session1'")
+		}
+	})
 }
 
 func TestHub_Unregister(t *testing.T) {
@@ -41,14 +44,14 @@ func TestHub_Unregister(t *testing.T) {
 	// Unregister client1
 	hub.Unregister(client1)
 
-	hub.mu.RLock()
-	if hub.rooms["session1"][client1] {
-		t.Error("expected client1 to be removed")
-	}
-	if !hub.rooms["session1"][client2] {
-		t.Error("expected client2 to remain")
-	}
-	hub.mu.RUnlock()
+	hub.call(func() {
+		if hub.rooms["session1"][client1] {
+			t.Error("expected client1 to be removed")
+		}
+		if !hub.rooms["session1"][client2] {
+			t.Error("expected client2 to remain")
+		}
+	})
 
 	// client1's Send channel should be closed
 	select {
@@ -63,11 +66,25 @@ func TestHub_Unregister(t *testing.T) {
 	// Unregister client2 (the last client in the room)
 	hub.Unregister(client2)
 
-	hub.mu.RLock()
-	if _, ok := hub.rooms["session1"]; ok {
-		t.Error("expected room 'session1' to be deleted when empty to free memory")
+	hub.call(func() {
+		if _, ok := hub.rooms["session1"]; ok {
+			t.Error("expected room 'session1' to be deleted when empty to free memory")
+		}
+	})
+}
+
+func TestHub_SessionIDs(t *testing.T) {
+	hub := NewHub(false)
+	client1 := &Client{SessionID: "session1", Send: make(chan []byte, 1)}
+	client2 := &Client{SessionID: "session2", Send: make(chan []byte, 1)}
+	hub.Register(client1)
+	hub.Register(client2)
+	hub.Unregister(client2)
+
+	ids := hub.SessionIDs()
+	if len(ids) != 1 || ids[0] != "session1" {
+		t.Errorf("expected [session1], got %v", ids)
 	}
-	hub.mu.RUnlock()
 }
 
 func TestHub_Broadcast(t *testing.T) {
@@ -80,14 +97,15 @@ func TestHub_Broadcast(t *testing.T) {
 	hub.Register(client2)
 	hub.Register(client3)
 
-	msg := []byte("hello session 1")
-	hub.Broadcast("session1", msg)
+	hub.Broadcast("session1", Event{Type: "HELLO", Payload: "session 1"})
 
 	// Check client 1 (should receive)
 	select {
 	case received := <-client1.Send:
-		if string(received) != string(msg) {
-			t.Errorf("client1 received %q, expected %q", received, msg)
+		var event Event
+		json.Unmarshal(received, &event)
+		if event.Type != "HELLO" || event.Payload != "session 1" || event.Seq == 0 {
+			t.Errorf("client1 received %s, expected a numbered HELLO event", received)
 		}
 	default:
 		t.Error("client1 did not receive message")
@@ -102,6 +120,76 @@ func TestHub_Broadcast(t *testing.T) {
 	}
 }
 
+func TestHub_BroadcastToRole(t *testing.T) {
+	hub := NewHub(false)
+	admin := &Client{SessionID: "session1", Role: RoleAdmin, Send: make(chan []byte, 256)}
+	participant := &Client{SessionID: "session1", Send: make(chan []byte, 256)}
+
+	hub.Register(admin)
+	hub.Register(participant)
+
+	hub.BroadcastToRole("session1", RoleAdmin, Event{Type: "QUESTION_PENDING"})
+
+	select {
+	case <-admin.Send:
+	default:
+		t.Error("admin did not receive message")
+	}
+	select {
+	case <-participant.Send:
+		t.Error("participant should not have received admin-only message")
+	default:
+	}
+}
+
+func TestHub_Presence(t *testing.T) {
+	hub := NewHub(false)
+	hub.presenceInterval = 50 * time.Millisecond
+	clients := make([]*Client, 3)
+	for i := range clients {
+		clients[i] = &Client{SessionID: "session1", Send: make(chan []byte, 256)}
+		hub.Register(clients[i])
+	}
+	if n := hub.ClientCount("session1"); n != 3 {
+		t.Fatalf("expected 3 clients, got %d", n)
+	}
+
+	expectPresence := func(t *testing.T, client *Client, count int) {
+		t.Helper()
+		select {
+		case msg := <-client.Send:
+			var event struct {
+				Type    string         `json:"type"`
+				Payload map[string]int `json:"payload"`
+			}
+			json.Unmarshal(msg, &event)
+			if event.Type != "PRESENCE" || event.Payload["count"] != count {
+				t.Errorf("expected PRESENCE with count %d, got %s", count, msg)
+			}
+		case <-time.After(time.Second):
+			t.Fatal("expected a PRESENCE event")
+		}
+	}
+
+	// The three joins are throttled into one event.
+	expectPresence(t, clients[0], 3)
+	time.Sleep(5 * hub.presenceInterval)
+	if len(clients[0].Send) != 0 {
+		t.Errorf("expected a single PRESENCE event, got %d more", len(clients[0].Send))
+	}
+
+	hub.Unregister(clients[2])
+	expectPresence(t, clients[0], 2)
+
+	// A leave and a join that cancel out send nothing.
+	hub.Unregister(clients[1])
+	hub.Register(&Client{SessionID: "session1", Send: make(chan []byte, 256)})
+	time.Sleep(5 * hub.presenceInterval)
+	if len(clients[0].Send) != 0 {
+		t.Errorf("expected no PRESENCE event for an unchanged count, got %d", len(clients[0].Send))
+	}
+}
+
 func TestHub_Broadcast_BlockedClient(t *testing.T) {
 	hub := NewHub(false)
 	// Create a client with a buffer size of 1
@@ -112,13 +200,118 @@ func TestHub_Broadcast_BlockedClient(t *testing.T) {
 	client.Send <- []byte("first message")
 
 	// This broadcast should encounter a blocked channel, and proactively drop the client
-	hub.Broadcast("session1", []byte("second message"))
+	hub.Broadcast("session1", Event{Type: "SECOND"})
 
-	hub.mu.RLock()
-	defer hub.mu.RUnlock()
+	if n := hub.ClientCount("session1"); n != 0 {
+		t.Errorf("expected client to be removed due to a full/blocked send buffer, %d left", n)
+	}
+	<-client.Send
+	if _, ok := <-client.Send; ok {
+		t.Error("expected the evicted client's send channel to be closed")
+	}
 
-	if hub.rooms["session1"][client] {
-		t.Error("expected client to be removed due to a full/blocked send buffer")
+	// Unregistering it afterwards, as its readPump does, must not close
+	// the channel again.
+	hub.Unregister(client)
+}
+
+func TestHub_ConcurrentLoad(t *testing.T) {
+	hub := NewHub(false)
+	const (
+		broadcasters = 8
+		events       = 200
+	)
+
+	// Fast clients have room for every event; every other one is an admin.
+	fast := make([]*Client, 4)
+	for i := range fast {
+		fast[i] = &Client{SessionID: "load", Send: make(chan []byte, 2*broadcasters*events)}
+		if i%2 == 1 {
+			fast[i].Role = RoleAdmin
+		}
+		hub.Register(fast[i])
+	}
+
+	// Slow clients never read, so the broadcasts evict them, while they also
+	// unregister themselves as a closing connection would.
+	var wg sync.WaitGroup
+	slow := make([]*Client, 8)
+	for i := range slow {
+		slow[i] = &Client{SessionID: "load", Send: make(chan []byte, 4)}
+		hub.Register(slow[i])
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			time.Sleep(time.Duration(i) * time.Millisecond)
+			hub.Unregister(slow[i])
+		}()
+	}
+
+	for b := 0; b < broadcasters; b++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for e := 0; e < events; e++ {
+				if b%2 == 0 {
+					hub.Broadcast("load", Event{Type: "PUBLIC"})
+				} else {
+					hub.BroadcastToRole("load", RoleAdmin, Event{Type: "ADMIN_ONLY"})
+				}
+			}
+		}()
+	}
+
+	// Clients come and go, in this room and another, meanwhile.
+	for c := 0; c < 4; c++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < 50; i++ {
+				client := &Client{SessionID: "load", Send: make(chan []byte, 1)}
+				if i%2 == 1 {
+					client.SessionID = "other"
+				}
+				hub.Register(client)
+				hub.ClientCount("load")
+				hub.SessionIDs()
+				hub.Unregister(client)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if n := hub.ClientCount("load"); n != len(fast) {
+		t.Errorf("expected the %d fast clients to remain, got %d", len(fast), n)
+	}
+	for _, client := range slow {
+		for range client.Send {
+		}
+	}
+	for i, client := range fast {
+		hub.Unregister(client)
+		want := broadcasters / 2 * events
+		if client.Role == RoleAdmin {
+			want *= 2
+		}
+		var last uint64
+		got := 0
+		for msg := range client.Send {
+			var event Event
+			if err := json.Unmarshal(msg, &event); err != nil {
+				t.Fatalf("invalid event %q: %v", msg, err)
+			}
+			if event.Seq == 0 {
+				continue // PRESENCE
+			}
+			if event.Seq <= last {
+				t.Errorf("client %d: event %d arrived after %d", i, event.Seq, last)
+			}
+			last = event.Seq
+			got++
+		}
+		if got != want {
+			t.Errorf("client %d: expected %d events, got %d", i, want, got)
+		}
 	}
 }
 
@@ -126,7 +319,7 @@ func TestHub_Broadcast_BlockedClient(t *testing.T) {
 func newTestServer(t *testing.T, hub *Hub, sessionID string) *httptest.Server {
 	t.Helper()
 	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		hub.ServeWS(w, r, sessionID)
+		hub.ServeWS(w, r, Connection{SessionID: sessionID, Role: RoleParticipant})
 	}))
 }
 
@@ -142,16 +335,10 @@ func dialTestServer(t *testing.T, server *httptest.Server) *websocket.Conn {
 }
 
 func TestWritePump_SendsPing(t *testing.T) {
-	pingPeriod = 60 * time.Millisecond
-	pongWait = 500 * time.Millisecond
-	writeWait = 100 * time.Millisecond
-	t.Cleanup(func() {
-		pingPeriod = 54 * time.Second
-		pongWait = 60 * time.Second
-		writeWait = 10 * time.Second
-	})
-
 	hub := NewHub(false)
+	hub.pingPeriod = 60 * time.Millisecond
+	hub.pongWait = 500 * time.Millisecond
+	hub.writeWait = 100 * time.Millisecond
 	server := newTestServer(t, hub, "s1")
 	defer server.Close()
 
@@ -181,22 +368,16 @@ func TestWritePump_SendsPing(t *testing.T) {
 	select {
 	case <-pingReceived:
 		// success
-	case <-time.After(2 * pingPeriod):
+	case <-time.After(2 * hub.pingPeriod):
 		t.Error("expected ping within 2x ping period, none received")
 	}
 }
 
 func TestReadPump_ClosesConnectionOnDeadline(t *testing.T) {
-	pongWait = 80 * time.Millisecond
-	pingPeriod = 60 * time.Millisecond
-	writeWait = 100 * time.Millisecond
-	t.Cleanup(func() {
-		pongWait = 60 * time.Second
-		pingPeriod = 54 * time.Second
-		writeWait = 10 * time.Second
-	})
-
 	hub := NewHub(false)
+	hub.pongWait = 80 * time.Millisecond
+	hub.pingPeriod = 60 * time.Millisecond
+	hub.writeWait = 100 * time.Millisecond
 	server := newTestServer(t, hub, "s2")
 	defer server.Close()
 
@@ -213,3 +394,267 @@ func TestReadPump_ClosesConnectionOnDeadline(t *testing.T) {
 		t.Fatal("expected connection to be closed by server, but ReadMessage succeeded")
 	}
 }
+
+func TestServeWS_AuthenticateFirstMessage(t *testing.T) {
+	hub := NewHub(false)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hub.ServeWS(w, r, Connection{
+			SessionID:    "s3",
+			Role:         RoleParticipant,
+			Authenticate: func(token string) bool { return token == "secret" },
+		})
+	}))
+	defer server.Close()
+
+	auth := func(t *testing.T, token string) (*websocket.Conn, string) {
+		t.Helper()
+		conn := dialTestServer(t, server)
+		if err := conn.WriteJSON(map[string]interface{}{"type": "AUTH", "payload": map[string]string{"token": token}}); err != nil {
+			t.Fatalf("write failed: %v", err)
+		}
+		conn.SetReadDeadline(time.Now().Add(time.Second))
+		var reply struct {
+			Type string `json:"type"`
+		}
+		if err := conn.ReadJSON(&reply); err != nil {
+			t.Fatalf("read failed: %v", err)
+		}
+		return conn, reply.Type
+	}
+
+	participant, reply := auth(t, "wrong")
+	defer participant.Close()
+	if reply != "AUTH_FAILED" {
+		t.Errorf("expected AUTH_FAILED for a wrong token, got %q", reply)
+	}
+	admin, reply := auth(t, "secret")
+	defer admin.Close()
+	if reply != "AUTH_OK" {
+		t.Fatalf("expected AUTH_OK, got %q", reply)
+	}
+
+	hub.BroadcastToRole("s3", RoleAdmin, Event{Type: "ADMIN_ONLY"})
+	hub.Broadcast("s3", Event{Type: "PUBLIC"})
+
+	for conn, want := range map[*websocket.Conn]string{admin: "ADMIN_ONLY", participant: "PUBLIC"} {
+		conn.SetReadDeadline(time.Now().Add(time.Second))
+		_, msg, err := conn.ReadMessage()
+		if err != nil {
+			t.Fatalf("read failed: %v", err)
+		}
+		if !strings.Contains(string(msg), want) {
+			t.Errorf("expected %s first, got %s", want, msg)
+		}
+	}
+}
+
+func TestServeWS_AuthSendsAdminSnapshot(t *testing.T) {
+	hub := NewHub(false)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hub.ServeWS(w, r, Connection{
+			SessionID:    "s6",
+			Role:         RoleParticipant,
+			Authenticate: func(token string) bool { return token == "secret" },
+			Snapshot: func(role Role) (interface{}, error) {
+				return map[string]bool{"admin": role == RoleAdmin}, nil
+			},
+		})
+	}))
+	defer server.Close()
+
+	conn := dialTestServer(t, server)
+	defer conn.Close()
+	read := func(t *testing.T) Event {
+		t.Helper()
+		conn.SetReadDeadline(time.Now().Add(time.Second))
+		var event Event
+		if err := conn.ReadJSON(&event); err != nil {
+			t.Fatalf("read failed: %v", err)
+		}
+		return event
+	}
+	isAdmin := func(event Event) interface{} {
+		payload, _ := event.Payload.(map[string]interface{})
+		return payload["admin"]
+	}
+
+	if event := read(t); event.Type != "SNAPSHOT" || isAdmin(event) != false {
+		t.Fatalf("expected the participant SNAPSHOT, got %+v", event)
+	}
+	if err := conn.WriteJSON(map[string]interface{}{"type": "AUTH", "payload": map[string]string{"token": "secret"}}); err != nil {
+		t.Fatalf("write failed: %v", err)
+	}
+	if event := read(t); event.Type != "AUTH_OK" {
+		t.Fatalf("expected AUTH_OK, got %+v", event)
+	}
+	if event := read(t); event.Type != "SNAPSHOT" || isAdmin(event) != true {
+		t.Errorf("expected the admin SNAPSHOT after AUTH_OK, got %+v", event)
+	}
+}
+
+func TestEventLog_Since(t *testing.T) {
+	l := newEventLog()
+	start := l.cursor()
+	for i := 0; i < historySize+10; i++ {
+		l.add([]byte{byte(i)}, everyone)
+	}
+
+	entries, ok := l.since(Cursor{Epoch: l.epoch, Seq: l.seq - 3})
+	if !ok || len(entries) != 3 {
+		t.Fatalf("expected the last 3 entries, got %d (ok=%v)", len(entries), ok)
+	}
+	if entries[0].message[0] != byte(historySize+7) {
+		t.Errorf("expected entries oldest first, got %v first", entries[0].message)
+	}
+	if entries, ok := l.since(l.cursor()); !ok || len(entries) != 0 {
+		t.Errorf("expected no entries after the latest seq, got %d (ok=%v)", len(entries), ok)
+	}
+	if _, ok := l.since(start); ok {
+		t.Error("expected evicted events to be unavailable")
+	}
+	if _, ok := l.since(Cursor{Epoch: l.epoch, Seq: l.seq + 1}); ok {
+		t.Error("expected a seq from the future to be unavailable")
+	}
+
+	fresh := newEventLog()
+	fresh.add([]byte{0}, everyone)
+	if _, ok := fresh.since(Cursor{Epoch: fresh.epoch, Seq: fresh.start - 1}); ok {
+		t.Error("expected a seq from before the log to be unavailable")
+	}
+	if _, ok := fresh.since(Cursor{Epoch: l.epoch, Seq: fresh.seq}); ok {
+		t.Error("expected a cursor from another log to be unavailable")
+	}
+}
+
+func TestServeWS_SnapshotAndReplay(t *testing.T) {
+	hub := NewHub(false)
+	var snapshots atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hub.ServeWS(w, r, Connection{
+			SessionID: "s4",
+			Role:      RoleParticipant,
+			LastEvent: ParseCursor(r.URL.Query().Get("lastSeq")),
+			Snapshot: func(Role) (interface{}, error) {
+				return map[string]int32{"count": snapshots.Add(1)}, nil
+			},
+		})
+	}))
+	defer server.Close()
+
+	dial := func(t *testing.T, last Cursor) *websocket.Conn {
+		t.Helper()
+		u := "ws" + strings.TrimPrefix(server.URL, "http") + "?lastSeq=" + url.QueryEscape(last.String())
+		conn, _, err := websocket.DefaultDialer.Dial(u, nil)
+		if err != nil {
+			t.Fatalf("dial failed: %v", err)
+		}
+		return conn
+	}
+	read := func(t *testing.T, conn *websocket.Conn) Event {
+		t.Helper()
+		conn.SetReadDeadline(time.Now().Add(time.Second))
+		var event Event
+		if err := conn.ReadJSON(&event); err != nil {
+			t.Fatalf("read failed: %v", err)
+		}
+		return event
+	}
+
+	// A fresh client gets a snapshot numbered with the latest seq.
+	first := dial(t, Cursor{})
+	defer first.Close()
+	snapshot := read(t, first)
+	if snapshot.Type != "SNAPSHOT" || snapshot.Seq == 0 || snapshot.Epoch == "" {
+		t.Fatalf("expected a numbered SNAPSHOT, got %+v", snapshot)
+	}
+
+	hub.Broadcast("s4", Event{Type: "ONE"})
+	hub.Broadcast("s4", Event{Type: "TWO"})
+	one, two := read(t, first), read(t, first)
+	if one.Seq != snapshot.Seq+1 || two.Seq != one.Seq+1 {
+		t.Fatalf("expected consecutive seqs after %d, got %d and %d", snapshot.Seq, one.Seq, two.Seq)
+	}
+
+	// A reconnecting client only gets what it missed.
+	t.Run("Replay", func(t *testing.T) {
+		conn := dial(t, Cursor{Epoch: one.Epoch, Seq: one.Seq})
+		defer conn.Close()
+		if event := read(t, conn); event.Type != "TWO" || event.Seq != two.Seq {
+			t.Errorf("expected TWO to be replayed, got %+v", event)
+		}
+		if n := snapshots.Load(); n != 1 {
+			t.Errorf("expected no new snapshot, got %d", n)
+		}
+	})
+
+	// A seq the log holds, but from another log, gets a new snapshot.
+	t.Run("OtherEpoch", func(t *testing.T) {
+		conn := dial(t, Cursor{Epoch: "another-log", Seq: one.Seq})
+		defer conn.Close()
+		if event := read(t, conn); event.Type != "SNAPSHOT" || event.Seq != two.Seq {
+			t.Errorf("expected a SNAPSHOT at seq %d, got %+v", two.Seq, event)
+		}
+	})
+
+	// A client that missed more than the log holds gets a new snapshot.
+	t.Run("Evicted", func(t *testing.T) {
+		for i := 0; i < historySize; i++ {
+			hub.Broadcast("s4", Event{Type: "FILLER"})
+		}
+		conn := dial(t, Cursor{Epoch: one.Epoch, Seq: one.Seq})
+		defer conn.Close()
+		if event := read(t, conn); event.Type != "SNAPSHOT" || event.Seq != two.Seq+historySize {
+			t.Errorf("expected a SNAPSHOT at seq %d, got %+v", two.Seq+historySize, event)
+		}
+	})
+}
+
+func TestServeWS_Commands(t *testing.T) {
+	hub := NewHub(false)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hub.ServeWS(w, r, Connection{
+			SessionID:    "s6",
+			Role:         RoleParticipant,
+			Authenticate: func(token string) bool { return token == "secret" },
+			Commands: func(role Role, cmd Command) Ack {
+				if role != RoleAdmin {
+					return Ack{Status: http.StatusForbidden, Error: "Unauthorized"}
+				}
+				return Ack{Status: http.StatusOK, Payload: cmd.Payload}
+			},
+		})
+	}))
+	defer server.Close()
+
+	conn := dialTestServer(t, server)
+	defer conn.Close()
+	send := func(t *testing.T, msg string) Ack {
+		t.Helper()
+		if err := conn.WriteMessage(websocket.TextMessage, []byte(msg)); err != nil {
+			t.Fatalf("write failed: %v", err)
+		}
+		conn.SetReadDeadline(time.Now().Add(time.Second))
+		var ack Ack
+		if err := conn.ReadJSON(&ack); err != nil {
+			t.Fatalf("read failed: %v", err)
+		}
+		return ack
+	}
+
+	// The first message is a command, not AUTH; it runs as a participant.
+	if ack := send(t, `{"type":"PIN","id":"a"}`); ack.Type != "ACK" || ack.ID != "a" || ack.OK || ack.Status != http.StatusForbidden {
+		t.Errorf("expected a failed ACK for a, got %+v", ack)
+	}
+	if ack := send(t, `not json`); ack.OK || ack.Status != http.StatusBadRequest {
+		t.Errorf("expected a 400 ACK for an invalid message, got %+v", ack)
+	}
+
+	hub.call(func() {
+		for client := range hub.rooms["s6"] {
+			client.Role = RoleAdmin
+		}
+	})
+	if ack := send(t, `{"type":"PIN","id":"b","payload":{"x":1}}`); !ack.OK || ack.ID != "b" || string(ack.Payload) != `{"x":1}` {
+		t.Errorf("expected a successful ACK for b with the admin role, got %+v", ack)
+	}
+}
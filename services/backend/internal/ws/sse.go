@@ -0,0 +1,86 @@
+package ws
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+)
+
+// ServeSSE streams the events of the client described by c as Server-Sent
+// Events, until the request ends or the client is evicted. The client joins
+// the session's room like a WebSocket one, but cannot send messages, so
+// c.Authenticate is unused. Each numbered event carries its cursor as the
+// event ID; without c.LastEvent, the client resumes from the Last-Event-ID
+// header that EventSource sends on reconnect.
+func (h *Hub) ServeSSE(w http.ResponseWriter, r *http.Request, c Connection) {
+	rc := http.NewResponseController(w)
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("X-Accel-Buffering", "no") // keep reverse proxies from buffering the stream
+	if err := rc.Flush(); err != nil {
+		log.Println("SSE stream error:", err)
+		http.Error(w, "Streaming unsupported", http.StatusInternalServerError)
+		return
+	}
+
+	last := c.LastEvent
+	if last.Seq == 0 {
+		// An invalid Last-Event-ID is treated as none.
+		last = ParseCursor(r.Header.Get("Last-Event-ID"))
+	}
+	client := &Client{
+		Hub:       h,
+		SessionID: c.SessionID,
+		Role:      c.Role,
+		Send:      make(chan []byte, 256),
+	}
+	if err := h.attach(client, last, c.Snapshot); err != nil {
+		log.Printf("Failed to load snapshot for session %q: %v", c.SessionID, err)
+		return
+	}
+	defer h.Unregister(client)
+
+	// Comments keep idle connections open through proxies, as pings do for
+	// WebSocket clients.
+	ticker := time.NewTicker(h.pingPeriod)
+	defer ticker.Stop()
+	for {
+		var err error
+		select {
+		case <-r.Context().Done():
+			return
+		case message, ok := <-client.Send:
+			if !ok {
+				return
+			}
+			rc.SetWriteDeadline(time.Now().Add(h.writeWait))
+			err = writeSSE(w, message)
+		case <-ticker.C:
+			rc.SetWriteDeadline(time.Now().Add(h.writeWait))
+			_, err = fmt.Fprint(w, ": ping\n\n")
+		}
+		if err == nil {
+			err = rc.Flush()
+		}
+		if err != nil {
+			return
+		}
+	}
+}
+
+// writeSSE writes an encoded event as a Server-Sent Event, with its cursor, if
+// it has one, as the event ID. The data is the same JSON a WebSocket client
+// gets.
+func writeSSE(w http.ResponseWriter, message []byte) error {
+	var cursor Cursor // from the event's seq and epoch fields
+	json.Unmarshal(message, &cursor)
+	if cursor.Seq != 0 {
+		if _, err := fmt.Fprintf(w, "id: %s\n", cursor); err != nil {
+			return err
+		}
+	}
+	_, err := fmt.Fprintf(w, "data: %s\n\n", message)
+	return err
+}
@@ -0,0 +1,108 @@
+package ws
+
+import (
+	"bufio"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// sseEvent is a Server-Sent Event as read by readSSE.
+type sseEvent struct {
+	ID    string
+	Event Event
+}
+
+// readSSE reads the next event from an SSE stream, skipping comments.
+func readSSE(t *testing.T, r *bufio.Reader) sseEvent {
+	t.Helper()
+	var e sseEvent
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			t.Fatalf("read failed: %v", err)
+		}
+		line = strings.TrimSuffix(line, "\n")
+		switch {
+		case line == "":
+			if e.Event.Type != "" {
+				return e
+			}
+		case strings.HasPrefix(line, "id: "):
+			e.ID = strings.TrimPrefix(line, "id: ")
+		case strings.HasPrefix(line, "data: "):
+			if err := json.Unmarshal([]byte(strings.TrimPrefix(line, "data: ")), &e.Event); err != nil {
+				t.Fatalf("invalid data %q: %v", line, err)
+			}
+		}
+	}
+}
+
+func TestServeSSE(t *testing.T) {
+	hub := NewHub(false)
+	hub.pingPeriod = 20 * time.Millisecond
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hub.ServeSSE(w, r, Connection{
+			SessionID: "s5",
+			Role:      RoleParticipant,
+			Snapshot:  func(Role) (interface{}, error) { return map[string]string{"sessionId": "s5"}, nil },
+		})
+	}))
+	defer server.Close()
+
+	connect := func(t *testing.T, lastEventID string) (*http.Response, *bufio.Reader) {
+		t.Helper()
+		req, _ := http.NewRequest(http.MethodGet, server.URL, nil)
+		if lastEventID != "" {
+			req.Header.Set("Last-Event-ID", lastEventID)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("request failed: %v", err)
+		}
+		if ct := resp.Header.Get("Content-Type"); ct != "text/event-stream" {
+			t.Fatalf("expected text/event-stream, got %q", ct)
+		}
+		return resp, bufio.NewReader(resp.Body)
+	}
+
+	resp, stream := connect(t, "")
+	defer resp.Body.Close()
+	snapshot := readSSE(t, stream)
+	if snapshot.Event.Type != "SNAPSHOT" || snapshot.ID != (Cursor{Epoch: snapshot.Event.Epoch, Seq: snapshot.Event.Seq}).String() {
+		t.Fatalf("expected a SNAPSHOT with its cursor as ID, got %+v", snapshot)
+	}
+
+	hub.Broadcast("s5", Event{Type: "QUESTION_ADDED"})
+	hub.BroadcastToRole("s5", RoleAdmin, Event{Type: "QUESTION_PENDING"})
+	hub.Broadcast("s5", Event{Type: "VOTE_UPDATED"})
+	added := readSSE(t, stream)
+	if added.Event.Type != "QUESTION_ADDED" || added.ID == "" {
+		t.Errorf("expected QUESTION_ADDED with an ID, got %+v", added)
+	}
+	if voted := readSSE(t, stream); voted.Event.Type != "VOTE_UPDATED" {
+		t.Errorf("expected VOTE_UPDATED after skipping the admin-only event, got %+v", voted)
+	}
+
+	t.Run("ResumesFromLastEventID", func(t *testing.T) {
+		resp, stream := connect(t, added.ID)
+		defer resp.Body.Close()
+		if e := readSSE(t, stream); e.Event.Type != "VOTE_UPDATED" {
+			t.Errorf("expected VOTE_UPDATED to be replayed, got %+v", e)
+		}
+	})
+
+	t.Run("UnregistersOnDisconnect", func(t *testing.T) {
+		resp.Body.Close()
+		deadline := time.Now().Add(time.Second)
+		for hub.ClientCount("s5") != 0 {
+			if time.Now().After(deadline) {
+				t.Fatalf("expected the client to be unregistered, %d left", hub.ClientCount("s5"))
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+	})
+}
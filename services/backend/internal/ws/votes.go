@@ -0,0 +1,115 @@
+package ws
+
+import (
+	"hash/fnv"
+	"sync"
+	"time"
+)
+
+// Types of the events that carry vote counts.
+const (
+	voteUpdatedType = "VOTE_UPDATED"
+	votesBatchType  = "VOTES_BATCH"
+)
+
+// voteCount is the VOTE_UPDATED event payload.
+type voteCount struct {
+	ID    string `json:"id"`
+	Votes int    `json:"votes"`
+}
+
+// votesBatch is the VOTES_BATCH event payload: the latest count of each
+// question voted on during the window.
+type votesBatch struct {
+	Votes map[string]int `json:"votes"`
+}
+
+// voteCoalescer collects the vote counts of each room for a short window, so
+// that a burst of votes costs each client one VOTES_BATCH event instead of
+// one event per vote.
+type voteCoalescer struct {
+	mu     sync.Mutex
+	window time.Duration
+	// pending maps a sessionID to the latest count of each question voted
+	// on since the room's last batch.
+	pending map[string]map[string]int
+
+	// ordering is held from taking a room's pending counts until they and
+	// the event that flushed them, if any, have been published, so that no
+	// other event of the room overtakes them. Rooms share its stripes.
+	ordering [32]sync.Mutex
+}
+
+// lock locks the ordering of the session's events and returns the unlock
+// function.
+func (v *voteCoalescer) lock(sessionID string) func() {
+	hash := fnv.New32a()
+	hash.Write([]byte(sessionID))
+	mu := &v.ordering[hash.Sum32()%uint32(len(v.ordering))]
+	mu.Lock()
+	return mu.Unlock
+}
+
+// SetVoteWindow sets how long BroadcastVotes collects the counts of a room
+// before it broadcasts them together. 0, the default, broadcasts each count
+// right away as a VOTE_UPDATED event.
+func (h *Hub) SetVoteWindow(window time.Duration) {
+	h.votes.mu.Lock()
+	defer h.votes.mu.Unlock()
+
+	h.votes.window = window
+}
+
+// BroadcastVotes sends the new vote count of a question to all clients of a
+// session. Within the vote window, the counts of the session's questions are
+// coalesced into one VOTES_BATCH event.
+func (h *Hub) BroadcastVotes(sessionID, questionID string, votes int) {
+	h.votes.mu.Lock()
+	if h.votes.window <= 0 {
+		h.votes.mu.Unlock()
+		// Counts collected before the window was closed go first.
+		h.broadcastAfterVotes(sessionID, Event{Type: voteUpdatedType, Payload: voteCount{ID: questionID, Votes: votes}}, nil)
+		return
+	}
+	room := h.votes.pending[sessionID]
+	if room == nil {
+		room = make(map[string]int)
+		h.votes.pending[sessionID] = room
+		time.AfterFunc(h.votes.window, func() { h.flushVotes(sessionID) })
+	}
+	room[questionID] = votes
+	h.votes.mu.Unlock()
+}
+
+// flushVotes broadcasts the session's pending vote counts, if any.
+func (h *Hub) flushVotes(sessionID string) {
+	unlock := h.votes.lock(sessionID)
+	defer unlock()
+
+	h.publishVotes(sessionID)
+}
+
+// broadcastAfterVotes broadcasts the session's pending vote counts, if any,
+// then event, for the clients with role or all of them. Other events of the
+// session go out this way so that clients get the counts before, say, the
+// deletion of a question.
+func (h *Hub) broadcastAfterVotes(sessionID string, event Event, role *Role) {
+	unlock := h.votes.lock(sessionID)
+	defer unlock()
+
+	h.publishVotes(sessionID)
+	h.fanOut(sessionID, event, role)
+}
+
+// publishVotes broadcasts the session's pending vote counts, if any. The
+// caller holds the session's ordering lock.
+func (h *Hub) publishVotes(sessionID string) {
+	h.votes.mu.Lock()
+	room := h.votes.pending[sessionID]
+	delete(h.votes.pending, sessionID)
+	h.votes.mu.Unlock()
+
+	if len(room) > 0 {
+		h.fanOut(sessionID, Event{Type: votesBatchType, Payload: votesBatch{Votes: room}}, nil)
+	}
+}
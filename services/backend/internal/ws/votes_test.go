@@ -0,0 +1,141 @@
+package ws
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+func TestHub_BroadcastVotes(t *testing.T) {
+	newRoom := func(t *testing.T, window time.Duration) (*Hub, *Client) {
+		t.Helper()
+		hub := NewHub(false)
+		hub.SetVoteWindow(window)
+		client := &Client{SessionID: "session1", Send: make(chan []byte, 16)}
+		hub.Register(client)
+		return hub, client
+	}
+	batchOf := func(t *testing.T, event Event) map[string]int {
+		t.Helper()
+		if event.Type != "VOTES_BATCH" {
+			t.Fatalf("expected VOTES_BATCH, got %+v", event)
+		}
+		var batch votesBatch
+		raw, _ := json.Marshal(event.Payload)
+		json.Unmarshal(raw, &batch)
+		return batch.Votes
+	}
+
+	t.Run("NoWindow", func(t *testing.T) {
+		hub, client := newRoom(t, 0)
+		hub.BroadcastVotes("session1", "q1", 4)
+		event := nextEvent(t, client)
+		payload, _ := event.Payload.(map[string]interface{})
+		if event.Type != "VOTE_UPDATED" || payload["id"] != "q1" || payload["votes"] != float64(4) || len(payload) != 2 {
+			t.Errorf("expected VOTE_UPDATED with the id and count only, got %+v", event)
+		}
+	})
+
+	t.Run("ClosingTheWindowFlushesFirst", func(t *testing.T) {
+		hub, client := newRoom(t, time.Hour)
+		hub.BroadcastVotes("session1", "q1", 5)
+		hub.SetVoteWindow(0)
+		hub.BroadcastVotes("session1", "q1", 6)
+
+		if votes := batchOf(t, nextEvent(t, client)); votes["q1"] != 5 {
+			t.Errorf("expected the pending count of q1 first, got %v", votes)
+		}
+		event := nextEvent(t, client)
+		payload, _ := event.Payload.(map[string]interface{})
+		if event.Type != "VOTE_UPDATED" || payload["votes"] != float64(6) {
+			t.Errorf("expected VOTE_UPDATED with 6 votes right away, got %+v", event)
+		}
+		hub.votes.mu.Lock()
+		pending := len(hub.votes.pending)
+		hub.votes.mu.Unlock()
+		if pending != 0 {
+			t.Errorf("expected no pending counts, got %d rooms", pending)
+		}
+	})
+
+	t.Run("CoalescesWithinWindow", func(t *testing.T) {
+		hub, client := newRoom(t, 50*time.Millisecond)
+		hub.BroadcastVotes("session1", "q1", 1)
+		hub.BroadcastVotes("session1", "q2", 7)
+		hub.BroadcastVotes("session1", "q1", 2)
+		hub.BroadcastVotes("session1", "q1", 3)
+		if n := len(client.Send); n != 0 {
+			t.Fatalf("expected no event before the window ends, got %d", n)
+		}
+
+		votes := batchOf(t, nextEvent(t, client))
+		if len(votes) != 2 || votes["q1"] != 3 || votes["q2"] != 7 {
+			t.Errorf("expected the latest count of q1 and q2, got %v", votes)
+		}
+		time.Sleep(100 * time.Millisecond)
+		if n := len(client.Send); n != 0 {
+			t.Errorf("expected a single batch, got %d more events", n)
+		}
+	})
+
+	t.Run("OtherEventsFlushFirst", func(t *testing.T) {
+		hub, client := newRoom(t, time.Hour)
+		hub.BroadcastVotes("session1", "q1", 5)
+		hub.Broadcast("session1", Event{Type: "QUESTION_DELETED", Payload: map[string]string{"id": "q1"}})
+
+		if votes := batchOf(t, nextEvent(t, client)); votes["q1"] != 5 {
+			t.Errorf("expected the pending count of q1, got %v", votes)
+		}
+		if event := nextEvent(t, client); event.Type != "QUESTION_DELETED" {
+			t.Errorf("expected QUESTION_DELETED right after the batch, got %+v", event)
+		}
+	})
+
+	t.Run("FlushIsNotOvertaken", func(t *testing.T) {
+		broker := &gatedBroker{LocalBroker: NewLocalBroker(), gate: make(chan struct{}), held: make(chan struct{})}
+		hub, err := NewHubWithBroker(false, broker)
+		if err != nil {
+			t.Fatalf("NewHubWithBroker failed: %v", err)
+		}
+		hub.SetVoteWindow(time.Hour)
+		client := &Client{SessionID: "session1", Send: make(chan []byte, 16)}
+		hub.Register(client)
+
+		hub.BroadcastVotes("session1", "q1", 5)
+		go hub.flushVotes("session1") // as the window's timer does
+		<-broker.held
+		deleted := make(chan struct{})
+		go func() {
+			hub.Broadcast("session1", Event{Type: "QUESTION_DELETED"})
+			close(deleted)
+		}()
+		// Give the deletion a chance to overtake the batch.
+		time.Sleep(20 * time.Millisecond)
+		close(broker.gate)
+		<-deleted
+
+		if votes := batchOf(t, nextEvent(t, client)); votes["q1"] != 5 {
+			t.Errorf("expected the batch being flushed first, got %v", votes)
+		}
+		if event := nextEvent(t, client); event.Type != "QUESTION_DELETED" {
+			t.Errorf("expected QUESTION_DELETED after the batch, got %+v", event)
+		}
+	})
+}
+
+// gatedBroker holds the publication of VOTES_BATCH events until gate is
+// closed, and signals held when it starts holding one.
+type gatedBroker struct {
+	*LocalBroker
+	gate chan struct{}
+	held chan struct{}
+}
+
+func (b *gatedBroker) Publish(ctx context.Context, sessionID string, message []byte) error {
+	if env, err := decodeEnvelope(message); err == nil && env.Type == votesBatchType {
+		b.held <- struct{}{}
+		<-b.gate
+	}
+	return b.LocalBroker.Publish(ctx, sessionID, message)
+}
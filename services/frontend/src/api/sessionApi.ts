@@ -182,10 +182,21 @@ export const checkAdminStatus = async (sessionId: string): Promise<{ isAdmin: bo
 /**
  * Creates a WebSocket connection for real-time session updates.
  * @param {string} sessionId
+ * @param {string} [lastSeq] - "<epoch>:<seq>" of the last event received, to resume after a reconnect
  * @returns {WebSocket}
  */
-export const createSessionWebSocket = (sessionId: string): WebSocket => {
+export const createSessionWebSocket = (sessionId: string, lastSeq?: string): WebSocket => {
   const protocol = window.location.protocol === 'https:' ? 'wss:' : 'ws:';
-  const wsUrl = `${protocol}//${window.location.host}${API_BASE}/${encodeURIComponent(sessionId)}/ws`;
+  const params = new URLSearchParams();
+  // With the admin token the connection also gets admin-only events and hidden questions.
+  const adminToken = localStorage.getItem(`adminToken_${sessionId}`);
+  if (adminToken) {
+    params.set('token', adminToken);
+  }
+  if (lastSeq) {
+    params.set('lastSeq', lastSeq);
+  }
+  const query = params.toString();
+  const wsUrl = `${protocol}//${window.location.host}${API_BASE}/${encodeURIComponent(sessionId)}/ws${query ? `?${query}` : ''}`;
   return new WebSocket(wsUrl);
 };
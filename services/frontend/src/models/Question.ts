@@ -4,5 +4,6 @@ export interface Question {
   session_id: string;
   text: string;
   votes: number;
-  voters: string[];
+  hasVoted?: boolean; // whether the current participant has voted
+  status?: 'open' | 'pinned' | 'answered' | 'hidden';
 }
@@ -113,26 +113,65 @@ function VotingSessionPage(): JSX.Element {
     let ws: WebSocket;
     let reconnectTimer: ReturnType<typeof setTimeout> | null = null;
     let unmounted = false;
+    let lastSeq = '';
 
     const connect = () => {
-      ws = createSessionWebSocket(sessionId);
+      ws = createSessionWebSocket(sessionId, lastSeq);
 
       ws.onmessage = (event) => {
         try {
           const data = JSON.parse(event.data);
+          if (data.seq) {
+            lastSeq = `${data.epoch}:${data.seq}`;
+          }
 
           switch (data.type) {
+            case 'SNAPSHOT':
+              setQuestions(data.payload.questions ?? []);
+              break;
+
+            // Events that follow a SNAPSHOT may already be part of it.
             case 'QUESTION_ADDED':
-              setQuestions((prev) => [...prev, data.payload].sort((a, b) => b.votes - a.votes));
+              setQuestions((prev) =>
+                prev.some((q) => q.id === data.payload.id)
+                  ? prev
+                  : [...prev, data.payload].sort((a, b) => b.votes - a.votes),
+              );
+              break;
+
+            case 'QUESTIONS_IMPORTED':
+              setQuestions((prev) => {
+                const added = data.payload.questions.filter((q: Question) => !prev.some((p) => p.id === q.id));
+                return [...prev, ...added].sort((a, b) => b.votes - a.votes);
+              });
               break;
 
             case 'VOTE_UPDATED':
               setQuestions((prev) => {
-                const updated = prev.map((q) => (q.id === data.payload.id ? data.payload : q));
+                // The event only carries the new count; keep the rest of the question.
+                const updated = prev.map((q) => (q.id === data.payload.id ? { ...q, votes: data.payload.votes } : q));
                 return updated.sort((a, b) => b.votes - a.votes);
               });
               break;
 
+            case 'VOTES_BATCH':
+              setQuestions((prev) => {
+                const votes: Record<string, number> = data.payload.votes;
+                const updated = prev.map((q) => (q.id in votes ? { ...q, votes: votes[q.id] } : q));
+                return updated.sort((a, b) => b.votes - a.votes);
+              });
+              break;
+
+            case 'QUESTION_UPDATED':
+              setQuestions((prev) =>
+                // A question coming back from hidden is new to participants.
+                prev.some((q) => q.id === data.payload.id)
+                  ? prev.map((q) => (q.id === data.payload.id ? { ...q, status: data.payload.status } : q))
+                  : [...prev, data.payload].sort((a, b) => b.votes - a.votes),
+              );
+              break;
+
+            case 'QUESTION_HIDDEN':
             case 'QUESTION_DELETED':
               setQuestions((prev) => prev.filter((q) => q.id !== data.payload.id));
               break;
@@ -15,7 +15,7 @@ describe('QuestionItem', () => {
     id: 'q1',
     session_id: sessionId,
     text: 'Is this a test question?',
-    voters: [],
+    hasVoted: false,
     votes: 5,
   };
 
@@ -47,7 +47,7 @@ describe('QuestionItem admin-only buttons', () => {
     id: 'q1',
     session_id: sessionId,
     text: 'Is this a test question?',
-    voters: [],
+    hasVoted: false,
     votes: 5,
   };
 
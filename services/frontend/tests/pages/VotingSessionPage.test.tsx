@@ -49,8 +49,8 @@ const mockSessionData: SessionData = {
   createdAt: new Date().toDateString(),
   isActive: true,
   questions: [  
-    { id: 'q1', session_id: 'test-session', text: 'Question 1', votes: 3, voters: [] },
-    { id: 'q2', session_id: 'test-session', text: 'Question 2', votes: 5, voters: [] },
+    { id: 'q1', session_id: 'test-session', text: 'Question 1', votes: 3, hasVoted: false },
+    { id: 'q2', session_id: 'test-session', text: 'Question 2', votes: 5, hasVoted: false },
   ] 
 };
 